@@ -0,0 +1,266 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Token is an access token.
+type Token struct {
+	AccessToken string
+	// TokenType is the authorization scheme. Default is "Bearer".
+	TokenType string
+	// Expiry is the expiration time of the token. Zero means that the token never expires.
+	Expiry time.Time
+}
+
+func (token Token) expiresWithin(now time.Time, margin time.Duration) bool {
+	return !token.Expiry.IsZero() && !now.Add(margin).Before(token.Expiry)
+}
+
+// AuthorizationHeader returns the value of the Authorization header for the token.
+func (token Token) AuthorizationHeader() string {
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + token.AccessToken
+}
+
+// TokenSource provides access tokens.
+type TokenSource interface {
+	Token(ctx context.Context) (Token, error)
+}
+
+// TokenFunc is an adapter to allow the use of ordinary functions as TokenSource.
+type TokenFunc func(ctx context.Context) (Token, error)
+
+// Token calls fn(ctx).
+func (fn TokenFunc) Token(ctx context.Context) (Token, error) {
+	return fn(ctx)
+}
+
+// StaticToken returns a source of a token which never expires.
+func StaticToken(accessToken string) TokenSource {
+	return TokenFunc(func(context.Context) (Token, error) {
+		return Token{AccessToken: accessToken}, nil
+	})
+}
+
+// FileToken returns a source which reads the token from the file.
+// The file is read again when its modification time or size changes,
+// so the token can be rotated by an external process.
+// Leading and trailing whitespace is trimmed.
+func FileToken(path string) TokenSource {
+	return &fileToken{path: path}
+}
+
+type fileToken struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	token   Token
+}
+
+func (*fileToken) checksFreshness() {}
+
+func (source *fileToken) Token(context.Context) (Token, error) {
+	info, errStat := os.Stat(source.path)
+	if errStat != nil {
+		return Token{}, errStat
+	}
+
+	source.mu.Lock()
+	defer source.mu.Unlock()
+
+	if source.token.AccessToken != "" && info.ModTime().Equal(source.modTime) && info.Size() == source.size {
+		return source.token, nil
+	}
+
+	data, errRead := os.ReadFile(source.path)
+	if errRead != nil {
+		return Token{}, errRead
+	}
+
+	accessToken := strings.TrimSpace(string(data))
+	if accessToken == "" {
+		return Token{}, errors.New("token file " + source.path + " is empty")
+	}
+
+	source.token = Token{AccessToken: accessToken}
+	source.modTime, source.size = info.ModTime(), info.Size()
+
+	return source.token, nil
+}
+
+// DefaultExpiryMargin is the time before the token expiry when a cached token is refreshed.
+const DefaultExpiryMargin = 30 * time.Second
+
+// CachedTokenSource caches tokens of the underlying source until they are close to expiry.
+// Concurrent calls share a single in-flight refresh.
+type CachedTokenSource struct {
+	source TokenSource
+	margin time.Duration
+
+	mu      sync.Mutex
+	token   Token
+	valid   bool
+	refresh *tokenRefresh
+}
+
+// freshTokenSource is implemented by sources which check the freshness of their tokens themselves,
+// so their tokens are not cached.
+type freshTokenSource interface {
+	checksFreshness()
+}
+
+type tokenRefresh struct {
+	done     chan struct{}
+	token    Token
+	err      error
+	canceled bool
+}
+
+var errTokenRefreshPanicked = errors.New("token refresh panicked")
+
+// NewCachedTokenSource creates a caching wrapper around the source.
+// Tokens are refreshed when they expire within the margin, a zero margin means DefaultExpiryMargin.
+func NewCachedTokenSource(source TokenSource, margin time.Duration) *CachedTokenSource {
+	if margin <= 0 {
+		margin = DefaultExpiryMargin
+	}
+	return &CachedTokenSource{source: source, margin: margin}
+}
+
+// Token returns the cached token or fetches a new one.
+// Sources which check the freshness of their tokens themselves, like FileToken, are called every time.
+// If a shared refresh is stopped by the context of the caller which started it,
+// the other callers start a new one.
+func (cache *CachedTokenSource) Token(ctx context.Context) (Token, error) {
+	if _, ok := cache.source.(freshTokenSource); ok {
+		return cache.source.Token(ctx)
+	}
+
+	for {
+		cache.mu.Lock()
+		if cache.valid && !cache.token.expiresWithin(time.Now(), cache.margin) {
+			token := cache.token
+			cache.mu.Unlock()
+			return token, nil
+		}
+
+		refresh := cache.refresh
+		if refresh == nil {
+			refresh = &tokenRefresh{done: make(chan struct{})}
+			cache.refresh = refresh
+			cache.mu.Unlock()
+
+			cache.fetch(ctx, refresh)
+			return refresh.token, refresh.err
+		}
+		cache.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return Token{}, ctx.Err()
+		case <-refresh.done:
+		}
+
+		// the refresh was stopped by the context of another caller, so it is tried again
+		if !refresh.canceled {
+			return refresh.token, refresh.err
+		}
+	}
+}
+
+// fetch runs the refresh and releases its waiters even if the source panics.
+func (cache *CachedTokenSource) fetch(ctx context.Context, refresh *tokenRefresh) {
+	defer func() {
+		cache.mu.Lock()
+		cache.refresh = nil
+		if refresh.err == nil {
+			cache.token, cache.valid = refresh.token, true
+		}
+		cache.mu.Unlock()
+		close(refresh.done)
+	}()
+
+	refresh.err = errTokenRefreshPanicked
+	refresh.token, refresh.err = cache.source.Token(ctx)
+	refresh.canceled = refresh.err != nil && ctx.Err() != nil
+}
+
+// Invalidate drops the cached token if it is the given one,
+// so the next call of Token fetches a new token.
+func (cache *CachedTokenSource) Invalidate(token Token) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.valid && cache.token.AccessToken == token.AccessToken {
+		cache.valid = false
+	}
+}
+
+// BearerAuth creates a wrapper which sets the Authorization header using tokens from the source.
+//
+// Tokens are cached until they are close to expiry, FileToken checks the file on every request instead.
+// If the server responds with 401 Unauthorized, the token is invalidated
+// and the request is retried once with a new token, if the request body can be rewound.
+func BearerAuth(source TokenSource) Wrapper {
+	cache, ok := source.(*CachedTokenSource)
+	if !ok {
+		cache = NewCachedTokenSource(source, 0)
+	}
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return bearerAuth(next, req, cache)
+		})
+	}
+}
+
+func bearerAuth(next Doer, req *http.Request, cache *CachedTokenSource) (*http.Response, error) {
+	ctx := req.Context()
+
+	token, errToken := cache.Token(ctx)
+	if errToken != nil {
+		return nil, errToken
+	}
+
+	authReq := req.Clone(ctx)
+	authReq.Header.Set("Authorization", token.AuthorizationHeader())
+
+	resp, err := next.Do(authReq)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	if hasBody(req) && req.GetBody == nil {
+		return resp, nil
+	}
+
+	cache.Invalidate(token)
+	newToken, errToken := cache.Token(ctx)
+	if errToken != nil || newToken.AccessToken == token.AccessToken {
+		return resp, nil
+	}
+
+	body, errRewind := rewindBody(req)
+	if errRewind != nil {
+		return resp, nil
+	}
+	drainAndClose(resp.Body)
+
+	retryReq := req.Clone(ctx)
+	retryReq.Body = body
+	retryReq.Header.Set("Authorization", newToken.AuthorizationHeader())
+
+	return next.Do(retryReq)
+}
@@ -0,0 +1,271 @@
+package httpclient_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ninedraft/httpclient"
+)
+
+func TestBearerAuth(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, "Bearer token-1", r.Header.Get("Authorization"), "authorization")
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	fetches := &atomic.Int32{}
+	source := httpclient.TokenFunc(func(context.Context) (httpclient.Token, error) {
+		n := fetches.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return httpclient.Token{
+			AccessToken: "token-" + strconv.Itoa(int(n)),
+			Expiry:      time.Now().Add(time.Hour),
+		}, nil
+	})
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.BearerAuth(source))
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp, err := client.Get(context.Background(), server.URL)
+			if err != nil {
+				t.Errorf("call error: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	assertEqual(t, 1, fetches.Load(), "token fetches")
+}
+
+func TestBearerAuth_Expiry(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	fetches := &atomic.Int32{}
+	source := httpclient.TokenFunc(func(context.Context) (httpclient.Token, error) {
+		fetches.Add(1)
+		return httpclient.Token{
+			AccessToken: "token",
+			Expiry:      time.Now().Add(time.Second),
+		}, nil
+	})
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.BearerAuth(source))
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(context.Background(), server.URL)
+		requireEqual(t, nil, err, "call error")
+		resp.Body.Close()
+	}
+
+	// the token expires within the default margin, so it is fetched every time
+	assertEqual(t, 3, fetches.Load(), "token fetches")
+}
+
+func TestCachedTokenSource_CanceledRefresh(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	fetches := &atomic.Int32{}
+	source := httpclient.TokenFunc(func(ctx context.Context) (httpclient.Token, error) {
+		if fetches.Add(1) == 1 {
+			close(started)
+			<-ctx.Done()
+			return httpclient.Token{}, ctx.Err()
+		}
+		return httpclient.Token{AccessToken: "token"}, nil
+	})
+	cache := httpclient.NewCachedTokenSource(source, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errFirst := make(chan error, 1)
+	go func() {
+		_, err := cache.Token(ctx)
+		errFirst <- err
+	}()
+	<-started
+
+	type result struct {
+		token httpclient.Token
+		err   error
+	}
+	second := make(chan result, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		token, err := cache.Token(ctx)
+		second <- result{token, err}
+	}()
+
+	// lets the second call wait for the shared refresh
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	assertEqual(t, context.Canceled, <-errFirst, "first call error")
+
+	got := <-second
+	requireEqual(t, nil, got.err, "second call error")
+	assertEqual(t, "token", got.token.AccessToken, "second call token")
+	assertEqual(t, 2, fetches.Load(), "token fetches")
+}
+
+func TestCachedTokenSource_Panic(t *testing.T) {
+	t.Parallel()
+
+	fetches := &atomic.Int32{}
+	source := httpclient.TokenFunc(func(context.Context) (httpclient.Token, error) {
+		if fetches.Add(1) == 1 {
+			panic("broken source")
+		}
+		return httpclient.Token{AccessToken: "token"}, nil
+	})
+	cache := httpclient.NewCachedTokenSource(source, 0)
+
+	func() {
+		defer func() {
+			assertEqual[any](t, "broken source", recover(), "recovered panic")
+		}()
+		_, _ = cache.Token(context.Background())
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	token, err := cache.Token(ctx)
+	requireEqual(t, nil, err, "call error")
+	assertEqual(t, "token", token.AccessToken, "token")
+}
+
+func TestBearerAuth_Unauthorized(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, `{"foo":"bar"}`, readString(t, r.Body), "request body")
+
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	fetches := &atomic.Int32{}
+	source := httpclient.TokenFunc(func(context.Context) (httpclient.Token, error) {
+		n := fetches.Add(1)
+		return httpclient.Token{AccessToken: "token-" + strconv.Itoa(int(n))}, nil
+	})
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.BearerAuth(source))
+
+	resp, errCall := client.PostJSON(context.Background(), server.URL, map[string]string{"foo": "bar"})
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+	assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+	assertEqual(t, 2, fetches.Load(), "token fetches")
+}
+
+func TestBearerAuth_StaticUnauthorized(t *testing.T) {
+	t.Parallel()
+
+	calls := &atomic.Int32{}
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		assertEqual(t, "Bearer static", r.Header.Get("Authorization"), "authorization")
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.BearerAuth(httpclient.StaticToken("static")))
+
+	resp, errCall := client.Get(context.Background(), server.URL)
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+	assertEqual(t, http.StatusUnauthorized, resp.StatusCode, "status code")
+	assertEqual(t, 1, calls.Load(), "calls")
+}
+
+func TestFileToken(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "token")
+	requireEqual(t, nil, os.WriteFile(path, []byte("first\n"), 0o600), "write token")
+
+	source := httpclient.FileToken(path)
+	ctx := context.Background()
+
+	token, errToken := source.Token(ctx)
+	requireEqual(t, nil, errToken, "token error")
+	assertEqual(t, "first", token.AccessToken, "token")
+
+	requireEqual(t, nil, os.WriteFile(path, []byte("second-token\n"), 0o600), "rewrite token")
+
+	token, errToken = source.Token(ctx)
+	requireEqual(t, nil, errToken, "token error")
+	assertEqual(t, "second-token", token.AccessToken, "rotated token")
+}
+
+func TestBearerAuth_FileToken(t *testing.T) {
+	t.Parallel()
+
+	var received []string
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	path := filepath.Join(t.TempDir(), "token")
+	requireEqual(t, nil, os.WriteFile(path, []byte("first"), 0o600), "write token")
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.BearerAuth(httpclient.FileToken(path)))
+
+	resp, errCall := client.Get(context.Background(), server.URL)
+	requireEqual(t, nil, errCall, "call error")
+	resp.Body.Close()
+
+	requireEqual(t, nil, os.WriteFile(path, []byte("second-token"), 0o600), "rotate token")
+
+	resp, errCall = client.Get(context.Background(), server.URL)
+	requireEqual(t, nil, errCall, "call error")
+	resp.Body.Close()
+
+	assertEqualSlices(t, []string{"Bearer first", "Bearer second-token"}, received, "authorization")
+}
+
+func TestToken_AuthorizationHeader(t *testing.T) {
+	t.Parallel()
+
+	assertEqual(t, "Bearer abc", httpclient.Token{AccessToken: "abc"}.AuthorizationHeader(), "default type")
+	assertEqual(t, "Bearer abc", httpclient.Token{AccessToken: "abc", TokenType: "bearer"}.AuthorizationHeader(), "bearer type")
+	assertEqual(t, "MAC abc", httpclient.Token{AccessToken: "abc", TokenType: "MAC"}.AuthorizationHeader(), "custom type")
+}
@@ -0,0 +1,283 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when a request is rejected by an open circuit breaker.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is a state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets all requests through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all requests with ErrCircuitOpen.
+	CircuitOpen
+	// CircuitHalfOpen lets a limited number of probe requests through.
+	CircuitHalfOpen
+)
+
+func (state CircuitState) String() string {
+	switch state {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("CircuitState(%d)", int(state))
+	}
+}
+
+// CircuitBreakerOptions configures the CircuitBreaker wrapper.
+// Zero values are replaced with defaults.
+type CircuitBreakerOptions struct {
+	// Key groups requests into independent circuits.
+	// Default is the request URL host.
+	Key func(req *http.Request) string
+
+	// ConsecutiveFailures opens the circuit after the given number of consecutive failures.
+	// Default is 5.
+	ConsecutiveFailures int
+
+	// FailureRatio opens the circuit when the ratio of failed requests reaches it.
+	// The ratio is checked only after MinRequests requests. Zero disables the check.
+	FailureRatio float64
+
+	// MinRequests is the minimum number of requests to check the FailureRatio.
+	// Default is 10.
+	MinRequests int
+
+	// Interval is the period of resetting the counters of a closed circuit.
+	// Zero means that the counters are reset only on state transitions.
+	Interval time.Duration
+
+	// Cooldown is the time an open circuit waits before letting probe requests through.
+	// Default is 30s.
+	Cooldown time.Duration
+
+	// HalfOpenProbes is the number of probe requests allowed in the half-open state.
+	// The circuit is closed if all of them succeed and opened again if any of them fails.
+	// Canceled probes are neither, they let another probe through.
+	// Default is 1.
+	HalfOpenProbes int
+
+	// IsFailure reports whether the request has failed.
+	// Default treats transport errors, except context cancellation, and 5xx responses as failures.
+	IsFailure func(resp *http.Response, err error) bool
+
+	// OnStateChange is called on every circuit state transition.
+	OnStateChange func(key string, from, to CircuitState)
+}
+
+func (opts CircuitBreakerOptions) withDefaults() CircuitBreakerOptions {
+	if opts.Key == nil {
+		opts.Key = func(req *http.Request) string { return req.URL.Host }
+	}
+	if opts.ConsecutiveFailures <= 0 {
+		opts.ConsecutiveFailures = 5
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = 10
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = 30 * time.Second
+	}
+	if opts.HalfOpenProbes <= 0 {
+		opts.HalfOpenProbes = 1
+	}
+	if opts.IsFailure == nil {
+		opts.IsFailure = isBreakerFailure
+	}
+	return opts
+}
+
+func isBreakerFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return resp.StatusCode >= 500
+}
+
+// CircuitBreaker creates a wrapper which stops sending requests to unhealthy hosts.
+//
+// A circuit is opened when failures reach the configured thresholds.
+// Requests to an open circuit fail immediately with ErrCircuitOpen.
+// After the cooldown the circuit becomes half-open and lets probe requests through,
+// which decide whether the circuit is closed or opened again.
+func CircuitBreaker(opts CircuitBreakerOptions) Wrapper {
+	breaker := &circuitBreaker{
+		opts:     opts.withDefaults(),
+		circuits: map[string]*circuit{},
+	}
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return breaker.do(next, req)
+		})
+	}
+}
+
+type circuitBreaker struct {
+	opts CircuitBreakerOptions
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state CircuitState
+	// generation is incremented on every transition,
+	// so the results of requests started in a previous state are ignored.
+	generation uint64
+
+	windowStart time.Time
+	openedAt    time.Time
+
+	requests    int
+	failures    int
+	consecutive int
+	probes      int
+	successes   int
+}
+
+type circuitTransition struct {
+	key      string
+	from, to CircuitState
+}
+
+func (breaker *circuitBreaker) do(next Doer, req *http.Request) (*http.Response, error) {
+	key := breaker.opts.Key(req)
+
+	generation, errAllow := breaker.allow(key, time.Now())
+	if errAllow != nil {
+		closeRequestBody(req, errAllow)
+		return nil, errAllow
+	}
+
+	resp, err := next.Do(req)
+	canceled := err != nil && errors.Is(err, context.Canceled)
+	breaker.record(key, generation, breaker.opts.IsFailure(resp, err), canceled, time.Now())
+
+	return resp, err
+}
+
+func (breaker *circuitBreaker) allow(key string, now time.Time) (uint64, error) {
+	breaker.mu.Lock()
+	var transitions []circuitTransition
+	defer func() {
+		breaker.mu.Unlock()
+		breaker.notify(transitions)
+	}()
+
+	c := breaker.circuits[key]
+	if c == nil {
+		c = &circuit{windowStart: now}
+		breaker.circuits[key] = c
+	}
+
+	switch c.state {
+	case CircuitClosed:
+		if breaker.opts.Interval > 0 && now.Sub(c.windowStart) >= breaker.opts.Interval {
+			c.reset(now)
+		}
+	case CircuitOpen:
+		if now.Sub(c.openedAt) < breaker.opts.Cooldown {
+			return 0, fmt.Errorf("%w: %s", ErrCircuitOpen, key)
+		}
+		transitions = append(transitions, c.transition(key, CircuitHalfOpen, now))
+		fallthrough
+	case CircuitHalfOpen:
+		if c.probes >= breaker.opts.HalfOpenProbes {
+			return 0, fmt.Errorf("%w: %s", ErrCircuitOpen, key)
+		}
+		c.probes++
+	}
+
+	return c.generation, nil
+}
+
+func (breaker *circuitBreaker) record(key string, generation uint64, failed, canceled bool, now time.Time) {
+	breaker.mu.Lock()
+	var transitions []circuitTransition
+	defer func() {
+		breaker.mu.Unlock()
+		breaker.notify(transitions)
+	}()
+
+	c := breaker.circuits[key]
+	if c == nil || c.generation != generation {
+		return
+	}
+
+	opts := breaker.opts
+	switch c.state {
+	case CircuitClosed:
+		c.requests++
+		if !failed {
+			c.consecutive = 0
+			return
+		}
+		c.failures++
+		c.consecutive++
+
+		ratio := float64(c.failures) / float64(c.requests)
+		if c.consecutive >= opts.ConsecutiveFailures ||
+			(opts.FailureRatio > 0 && c.requests >= opts.MinRequests && ratio >= opts.FailureRatio) {
+			transitions = append(transitions, c.transition(key, CircuitOpen, now))
+		}
+	case CircuitHalfOpen:
+		if failed {
+			transitions = append(transitions, c.transition(key, CircuitOpen, now))
+			return
+		}
+		// a canceled probe says nothing about the host, so its slot is released for another probe
+		if canceled {
+			c.probes--
+			return
+		}
+		c.successes++
+		if c.successes >= opts.HalfOpenProbes {
+			transitions = append(transitions, c.transition(key, CircuitClosed, now))
+		}
+	}
+}
+
+func (breaker *circuitBreaker) notify(transitions []circuitTransition) {
+	if breaker.opts.OnStateChange == nil {
+		return
+	}
+	for _, tr := range transitions {
+		breaker.opts.OnStateChange(tr.key, tr.from, tr.to)
+	}
+}
+
+func (c *circuit) transition(key string, to CircuitState, now time.Time) circuitTransition {
+	tr := circuitTransition{key: key, from: c.state, to: to}
+
+	c.state = to
+	c.generation++
+	c.reset(now)
+	if to == CircuitOpen {
+		c.openedAt = now
+	}
+
+	return tr
+}
+
+func (c *circuit) reset(now time.Time) {
+	c.windowStart = now
+	c.requests = 0
+	c.failures = 0
+	c.consecutive = 0
+	c.probes = 0
+	c.successes = 0
+}
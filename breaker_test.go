@@ -0,0 +1,235 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ninedraft/httpclient"
+)
+
+type stateRecorder struct {
+	mu          sync.Mutex
+	transitions []string
+}
+
+func (rec *stateRecorder) OnStateChange(key string, from, to httpclient.CircuitState) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.transitions = append(rec.transitions, from.String()+"->"+to.String())
+}
+
+func (rec *stateRecorder) Transitions() []string {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]string(nil), rec.transitions...)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	healthy := &atomic.Bool{}
+	calls := &atomic.Int32{}
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	rec := &stateRecorder{}
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.CircuitBreaker(httpclient.CircuitBreakerOptions{
+		ConsecutiveFailures: 3,
+		Cooldown:            20 * time.Millisecond,
+		OnStateChange:       rec.OnStateChange,
+	}))
+
+	get := func() (int, error) {
+		resp, err := client.Get(context.Background(), server.URL)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		status, err := get()
+		requireEqual(t, nil, err, "call %d error", i)
+		assertEqual(t, http.StatusInternalServerError, status, "call %d status", i)
+	}
+
+	_, errOpen := get()
+	assertEqual(t, true, errors.Is(errOpen, httpclient.ErrCircuitOpen), "expected open circuit, got %v", errOpen)
+	assertEqual(t, 3, calls.Load(), "server calls")
+
+	time.Sleep(30 * time.Millisecond)
+
+	// failed probe opens the circuit again
+	status, errProbe := get()
+	requireEqual(t, nil, errProbe, "probe error")
+	assertEqual(t, http.StatusInternalServerError, status, "probe status")
+
+	_, errOpen = get()
+	assertEqual(t, true, errors.Is(errOpen, httpclient.ErrCircuitOpen), "expected open circuit, got %v", errOpen)
+
+	time.Sleep(30 * time.Millisecond)
+	healthy.Store(true)
+
+	status, errProbe = get()
+	requireEqual(t, nil, errProbe, "probe error")
+	assertEqual(t, http.StatusOK, status, "probe status")
+
+	status, errClosed := get()
+	requireEqual(t, nil, errClosed, "closed circuit error")
+	assertEqual(t, http.StatusOK, status, "closed circuit status")
+
+	assertEqualSlices(t, []string{
+		"closed->open",
+		"open->half-open",
+		"half-open->open",
+		"open->half-open",
+		"half-open->closed",
+	}, rec.Transitions(), "transitions")
+}
+
+func TestCircuitBreaker_CanceledProbe(t *testing.T) {
+	t.Parallel()
+
+	healthy := &atomic.Bool{}
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("hang") {
+			<-r.Context().Done()
+			return
+		}
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	rec := &stateRecorder{}
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.CircuitBreaker(httpclient.CircuitBreakerOptions{
+		ConsecutiveFailures: 1,
+		Cooldown:            20 * time.Millisecond,
+		OnStateChange:       rec.OnStateChange,
+	}))
+
+	resp, err := client.Get(context.Background(), server.URL)
+	requireEqual(t, nil, err, "call error")
+	resp.Body.Close()
+
+	time.Sleep(30 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, errProbe := client.Get(ctx, server.URL+"?hang")
+	assertEqual(t, true, errors.Is(errProbe, context.Canceled), "expected canceled probe, got %v", errProbe)
+
+	// the canceled probe neither closes the circuit nor takes the probe slot
+	resp, err = client.Get(context.Background(), server.URL)
+	requireEqual(t, nil, err, "probe error")
+	resp.Body.Close()
+	assertEqual(t, http.StatusInternalServerError, resp.StatusCode, "probe status")
+
+	assertEqualSlices(t, []string{
+		"closed->open",
+		"open->half-open",
+		"half-open->open",
+	}, rec.Transitions(), "transitions")
+}
+
+func TestCircuitBreaker_FailureRatio(t *testing.T) {
+	t.Parallel()
+
+	calls := &atomic.Int32{}
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		// every second request fails
+		if calls.Add(1)%2 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.CircuitBreaker(httpclient.CircuitBreakerOptions{
+		FailureRatio: 0.5,
+		MinRequests:  4,
+		Cooldown:     time.Hour,
+	}))
+
+	for i := 0; i < 4; i++ {
+		resp, err := client.Get(context.Background(), server.URL)
+		requireEqual(t, nil, err, "call %d error", i)
+		resp.Body.Close()
+	}
+
+	_, errOpen := client.Get(context.Background(), server.URL)
+	assertEqual(t, true, errors.Is(errOpen, httpclient.ErrCircuitOpen), "expected open circuit, got %v", errOpen)
+}
+
+func TestCircuitBreaker_Key(t *testing.T) {
+	t.Parallel()
+
+	client := httpclient.NewFrom(httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "unhealthy.example.com" {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	}))
+	client.Use(httpclient.CircuitBreaker(httpclient.CircuitBreakerOptions{
+		ConsecutiveFailures: 1,
+		Cooldown:            time.Hour,
+	}))
+
+	ctx := context.Background()
+
+	_, errFirst := client.Get(ctx, "http://unhealthy.example.com")
+	assertEqual(t, false, errors.Is(errFirst, httpclient.ErrCircuitOpen), "first call must reach the doer")
+
+	_, errOpen := client.Get(ctx, "http://unhealthy.example.com")
+	assertEqual(t, true, errors.Is(errOpen, httpclient.ErrCircuitOpen), "expected open circuit, got %v", errOpen)
+
+	_, errHealthy := client.Get(ctx, "http://healthy.example.com")
+	assertEqual(t, nil, errHealthy, "other hosts must not be affected")
+}
+
+func TestCircuitBreaker_ClosesRejectedBody(t *testing.T) {
+	t.Parallel()
+
+	errTransport := errors.New("transport error")
+	doer := httpclient.CircuitBreaker(httpclient.CircuitBreakerOptions{
+		ConsecutiveFailures: 1,
+		Cooldown:            time.Hour,
+	})(httpclient.DoerFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errTransport
+	}))
+
+	req, errReq := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	requireEqual(t, nil, errReq, "request error")
+	_, errFirst := doer.Do(req)
+	requireEqual(t, errTransport, errFirst, "first call error")
+
+	body := &closeTracker{Reader: strings.NewReader("body")}
+	req, errReq = http.NewRequest(http.MethodPost, "http://example.com", body)
+	requireEqual(t, nil, errReq, "request error")
+
+	_, errOpen := doer.Do(req)
+
+	assertEqual(t, true, errors.Is(errOpen, httpclient.ErrCircuitOpen), "expected open circuit, got %v", errOpen)
+	assertEqual(t, true, body.closed.Load(), "rejected body is closed")
+}
@@ -6,6 +6,7 @@ import (
 	"net"
 	"net/http"
 	"net/textproto"
+	"net/url"
 	"time"
 
 	"golang.org/x/exp/slices"
@@ -16,9 +17,36 @@ const headerContentType = "Content-Type"
 // Client is a thin wrapper around http.Client.
 // It provides a convenient way to set default headers and middleware.
 type Client struct {
-	Header     http.Header
-	Doer       Doer
+	Header http.Header
+	Doer   Doer
+
+	// Middleware modifies requests before they are sent.
+	// It is the first stage of the request pipeline and runs before the Chain wrappers.
 	Middleware func(req *http.Request) (*http.Request, error)
+
+	// Chain of wrappers around the Doer. The first wrapper is the outermost one.
+	// See Client.Use.
+	Chain []Wrapper
+
+	// BaseURL is used to resolve relative request addresses, see Client.Get.
+	// Its query parameters are added to every request with a relative address.
+	BaseURL *url.URL
+
+	// AcceptStatus enables response validation.
+	// If not empty, responses with status codes outside of the given ranges
+	// are closed and reported as *StatusError.
+	AcceptStatus []StatusRange
+
+	// MultipartMaxMemory enables buffered multipart bodies.
+	// If positive, multipart bodies are written before the request is sent:
+	// in memory up to MultipartMaxMemory bytes, then to a temporary file.
+	// Such requests have ContentLength and GetBody set, so they can be retried and redirected.
+	// Otherwise multipart bodies are streamed.
+	MultipartMaxMemory int64
+
+	// Codecs by media type are used to decode responses by their Content-Type, see Client.Decode.
+	// Built-in JSON and XML codecs are used for media types without a codec. See Client.RegisterCodec.
+	Codecs map[string]Codec
 }
 
 // New returns a new Client with default settings.
@@ -57,76 +85,111 @@ type Doer interface {
 }
 
 // Get makes a GET request to the given address.
-func (client *Client) Get(ctx context.Context, addr string) (*http.Response, error) {
-	req, err := client.newRequest(ctx, http.MethodGet, addr, nil)
-	if err != nil {
-		return nil, err
-	}
-	return client.Doer.Do(req)
+//
+// Addresses of all the request methods are resolved against Client.BaseURL as RFC 3986 references,
+// so a base URL with a path should end with a slash: "https://example.com/api/" + "users"
+// results in "https://example.com/api/users".
+// Placeholders like "{id}" in the address path are replaced with values set by PathParam.
+func (client *Client) Get(ctx context.Context, addr string, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(http.MethodGet, addr).Apply(opts...).Do(ctx)
 }
 
 // Post makes a POST request to the given address.
-func (client *Client) Post(ctx context.Context, addr, bodyType string, body io.Reader) (*http.Response, error) {
-	return client.doBody(ctx, http.MethodPost, addr, bodyType, body)
+func (client *Client) Post(ctx context.Context, addr, bodyType string, body io.Reader, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(http.MethodPost, addr).Body(bodyType, body).Apply(opts...).Do(ctx)
 }
 
 // Put makes a PUT request to the given address.
-func (client *Client) Put(ctx context.Context, addr, bodyType string, body io.Reader) (*http.Response, error) {
-	return client.doBody(ctx, http.MethodPut, addr, bodyType, body)
+func (client *Client) Put(ctx context.Context, addr, bodyType string, body io.Reader, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(http.MethodPut, addr).Body(bodyType, body).Apply(opts...).Do(ctx)
 }
 
 // Patch makes a PATCH request to the given address.
-func (client *Client) Patch(ctx context.Context, addr, bodyType string, body io.Reader) (*http.Response, error) {
-	return client.doBody(ctx, http.MethodPatch, addr, bodyType, body)
+func (client *Client) Patch(ctx context.Context, addr, bodyType string, body io.Reader, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(http.MethodPatch, addr).Body(bodyType, body).Apply(opts...).Do(ctx)
 }
 
 const methodQuery = "QUERY"
 
 // Query makes a QUERY request to the given address.
 // QUERY methods // https://www.ietf.org/archive/id/draft-ietf-httpbis-safe-method-w-body-02.html#name-introduction.
-func (client *Client) Query(ctx context.Context, addr, bodyType string, body io.Reader) (*http.Response, error) {
-	return client.doBody(ctx, methodQuery, addr, bodyType, body)
+func (client *Client) Query(ctx context.Context, addr, bodyType string, body io.Reader, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(methodQuery, addr).Body(bodyType, body).Apply(opts...).Do(ctx)
 }
 
-func (client *Client) doBody(ctx context.Context, method, addr, bodyType string, body io.Reader) (*http.Response, error) {
-	req, err := client.newRequest(ctx, method, addr, body)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set(headerContentType, bodyType)
+// Delete makes a DELETE request to the given address.
+func (client *Client) Delete(ctx context.Context, addr string, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(http.MethodDelete, addr).Apply(opts...).Do(ctx)
+}
 
-	return client.Doer.Do(req)
+// Head makes a HEAD request to the given address.
+func (client *Client) Head(ctx context.Context, addr string, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(http.MethodHead, addr).Apply(opts...).Do(ctx)
 }
 
-// Delete makes a DELETE request to the given address.
-func (client *Client) Delete(ctx context.Context, addr string) (*http.Response, error) {
-	req, err := client.newRequest(ctx, http.MethodDelete, addr, nil)
-	if err != nil {
-		return nil, err
-	}
-	return client.Doer.Do(req)
+// Options makes a OPTIONS request to the given address.
+func (client *Client) Options(ctx context.Context, addr string, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(http.MethodOptions, addr).Apply(opts...).Do(ctx)
 }
 
-// Head makes a HEAD request to the given address.
-func (client *Client) Head(ctx context.Context, addr string) (*http.Response, error) {
-	req, err := client.newRequest(ctx, http.MethodHead, addr, nil)
+// do applies the request headers from the options and executes the request.
+// Request headers replace the client and body headers with the same keys.
+func (client *Client) do(req *http.Request, options *requestOptions) (*http.Response, error) {
+	for key, values := range options.header {
+		req.Header[key] = slices.Clone(values)
+	}
+
+	resp, err := client.doer().Do(req)
 	if err != nil {
-		return nil, err
+		// like http.Client, the body is closed on errors,
+		// even if a wrapper rejected the request before the transport.
+		closeRequestBody(req, err)
+		return resp, err
 	}
-	return client.Doer.Do(req)
+
+	accept := client.acceptedStatus(options)
+	if len(accept) == 0 || acceptStatus(accept, resp.StatusCode) {
+		return resp, nil
+	}
+
+	errStatus := newStatusError(resp)
+	drainAndClose(resp.Body)
+
+	return nil, errStatus
 }
 
-// Options makes a OPTIONS request to the given address.
-func (client *Client) Options(ctx context.Context, addr string) (*http.Response, error) {
-	req, err := client.newRequest(ctx, http.MethodOptions, addr, nil)
-	if err != nil {
-		return nil, err
+// acceptedStatus returns the status ranges accepted for the request, Expect overrides Client.AcceptStatus.
+// Empty ranges mean that the status codes are not checked.
+func (client *Client) acceptedStatus(options *requestOptions) []StatusRange {
+	if options.expect != nil {
+		return options.expect
+	}
+	return client.AcceptStatus
+}
+
+// closeRequestBody closes the body of a failed request.
+// Pipes are closed with the error, so their writers stop with the cause.
+func closeRequestBody(req *http.Request, cause error) {
+	switch body := req.Body.(type) {
+	case nil:
+	case *io.PipeReader:
+		_ = body.CloseWithError(cause)
+	default:
+		_ = body.Close()
 	}
-	return client.Doer.Do(req)
 }
 
-func (client *Client) newRequest(ctx context.Context, method, addr string, body io.Reader) (*http.Request, error) {
-	req, err := http.NewRequestWithContext(ctx, method, addr, body)
+func (client *Client) newRequest(ctx context.Context, method, addr string, body io.Reader, options *requestOptions) (*http.Request, error) {
+	u, errURL := client.requestURL(addr, options)
+	if errURL != nil {
+		return nil, errURL
+	}
+
+	if len(options.pathParams) > 0 && Route(ctx) == "" {
+		ctx = WithRoute(ctx, routeTemplate(addr))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
 	if err != nil {
 		return nil, err
 	}
@@ -136,17 +199,5 @@ func (client *Client) newRequest(ctx context.Context, method, addr string, body
 		req.Header[key] = slices.Clone(v)
 	}
 
-	req, errReq := client.wrapRequest(req)
-	if errReq != nil {
-		return nil, errReq
-	}
-
-	return req, nil
-}
-
-func (client *Client) wrapRequest(req *http.Request) (*http.Request, error) {
-	if client.Middleware != nil {
-		return client.Middleware(req)
-	}
 	return req, nil
 }
@@ -16,17 +16,17 @@ var methodsBody = map[string]callFn{
 	http.MethodGet:     (*httpclient.Client).Get,
 	http.MethodDelete:  (*httpclient.Client).Delete,
 	http.MethodOptions: (*httpclient.Client).Options,
-	http.MethodPatch: func(cl *httpclient.Client, ctx context.Context, addr string) (*http.Response, error) {
-		return cl.Patch(ctx, addr, "text/plain", bytes.NewBufferString("hello, world"))
+	http.MethodPatch: func(cl *httpclient.Client, ctx context.Context, addr string, opts ...httpclient.RequestOption) (*http.Response, error) {
+		return cl.Patch(ctx, addr, "text/plain", bytes.NewBufferString("hello, world"), opts...)
 	},
-	http.MethodPost: func(cl *httpclient.Client, ctx context.Context, addr string) (*http.Response, error) {
-		return cl.Post(ctx, addr, "text/plain", bytes.NewBufferString("hello, world"))
+	http.MethodPost: func(cl *httpclient.Client, ctx context.Context, addr string, opts ...httpclient.RequestOption) (*http.Response, error) {
+		return cl.Post(ctx, addr, "text/plain", bytes.NewBufferString("hello, world"), opts...)
 	},
-	http.MethodPut: func(cl *httpclient.Client, ctx context.Context, addr string) (*http.Response, error) {
-		return cl.Put(ctx, addr, "text/plain", bytes.NewBufferString("hello, world"))
+	http.MethodPut: func(cl *httpclient.Client, ctx context.Context, addr string, opts ...httpclient.RequestOption) (*http.Response, error) {
+		return cl.Put(ctx, addr, "text/plain", bytes.NewBufferString("hello, world"), opts...)
 	},
-	MethodQuery: func(cl *httpclient.Client, ctx context.Context, addr string) (*http.Response, error) {
-		return cl.Query(ctx, addr, "text/plain", bytes.NewBufferString("hello, world"))
+	MethodQuery: func(cl *httpclient.Client, ctx context.Context, addr string, opts ...httpclient.RequestOption) (*http.Response, error) {
+		return cl.Query(ctx, addr, "text/plain", bytes.NewBufferString("hello, world"), opts...)
 	},
 }
 
@@ -77,7 +77,7 @@ func TestClient_Simple(t *testing.T) {
 	}
 }
 
-type callFn = func(cl *httpclient.Client, ctx context.Context, addr string) (*http.Response, error)
+type callFn = func(cl *httpclient.Client, ctx context.Context, addr string, opts ...httpclient.RequestOption) (*http.Response, error)
 
 type testMethod struct {
 	Method string
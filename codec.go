@@ -0,0 +1,257 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ErrUnsupportedMediaType is returned when there is no codec for the media type of a response.
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// Codec encodes and decodes request and response bodies of a media type.
+// Codecs of other formats like CBOR, MessagePack or protobuf are registered with Client.RegisterCodec.
+type Codec interface {
+	// MediaType is used as the Content-Type of encoded request bodies, e.g. "application/cbor".
+	MediaType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+	Encode(w io.Writer, v any) error
+	Decode(r io.Reader, v any) error
+}
+
+var (
+	_ Codec = JSONCodec{}
+	_ Codec = XMLCodec{}
+)
+
+// JSONCodec is the encoding/json codec of "application/json".
+type JSONCodec struct{}
+
+func (JSONCodec) MediaType() string {
+	return "application/json"
+}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (JSONCodec) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (JSONCodec) Decode(r io.Reader, v any) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// XMLCodec is the encoding/xml codec of "application/xml".
+// Encoded values have no XML declaration.
+// Decoding follows the encoding of the XML declaration, see DecodeXML for the supported charsets.
+type XMLCodec struct{}
+
+func (XMLCodec) MediaType() string {
+	return "application/xml"
+}
+
+func (XMLCodec) Marshal(v any) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (codec XMLCodec) Unmarshal(data []byte, v any) error {
+	return codec.Decode(bytes.NewReader(data), v)
+}
+
+func (XMLCodec) Encode(w io.Writer, v any) error {
+	return xml.NewEncoder(w).Encode(v)
+}
+
+func (XMLCodec) Decode(r io.Reader, v any) error {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = charsetReader
+	return decoder.Decode(v)
+}
+
+// decodeCharset decodes the body in the charset of the Content-Type header,
+// which takes precedence over the XML declaration.
+func (XMLCodec) decodeCharset(r io.Reader, charset string, v any) error {
+	reader, errCharset := charsetReader(charset, r)
+	if errCharset != nil {
+		return errCharset
+	}
+
+	decoder := xml.NewDecoder(reader)
+	// the body is already converted to UTF-8
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder.Decode(v)
+}
+
+// charsetDecoder is implemented by codecs which honor the charset parameter of the Content-Type header.
+type charsetDecoder interface {
+	decodeCharset(r io.Reader, charset string, v any) error
+}
+
+// defaultCodecs are used for media types without a codec in Client.Codecs.
+var defaultCodecs = map[string]Codec{
+	"application/json": JSONCodec{},
+	"application/xml":  XMLCodec{},
+	"text/xml":         XMLCodec{},
+}
+
+// RegisterCodec sets the codec of its media type and the given additional media types in Client.Codecs.
+// Like the other Client fields, codecs must not be registered concurrently with requests.
+func (client *Client) RegisterCodec(codec Codec, mediaTypes ...string) {
+	if client.Codecs == nil {
+		client.Codecs = map[string]Codec{}
+	}
+	for _, mediaType := range append([]string{codec.MediaType()}, mediaTypes...) {
+		client.Codecs[strings.ToLower(mediaType)] = codec
+	}
+}
+
+// codec returns the codec of the content type.
+// Media types with a structured syntax suffix like "application/problem+json"
+// fall back to the codec of the suffix, e.g. "application/json".
+func (client *Client) codec(contentType string) (Codec, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	candidates := []string{mediaType}
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		candidates = append(candidates, "application/"+mediaType[i+1:])
+	}
+
+	for _, candidate := range candidates {
+		if codec, ok := client.Codecs[candidate]; ok {
+			return codec, nil
+		}
+		if codec, ok := defaultCodecs[candidate]; ok {
+			return codec, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedMediaType, contentType)
+}
+
+// PostAs makes a POST request to the given address with the body encoded by the codec.
+func (client *Client) PostAs(ctx context.Context, addr string, codec Codec, obj any, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(http.MethodPost, addr).Encode(codec, obj).Apply(opts...).Do(ctx)
+}
+
+// PutAs makes a PUT request to the given address with the body encoded by the codec.
+func (client *Client) PutAs(ctx context.Context, addr string, codec Codec, obj any, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(http.MethodPut, addr).Encode(codec, obj).Apply(opts...).Do(ctx)
+}
+
+// PatchAs makes a PATCH request to the given address with the body encoded by the codec.
+func (client *Client) PatchAs(ctx context.Context, addr string, codec Codec, obj any, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(http.MethodPatch, addr).Encode(codec, obj).Apply(opts...).Do(ctx)
+}
+
+// QueryAs makes a QUERY request to the given address with the body encoded by the codec.
+func (client *Client) QueryAs(ctx context.Context, addr string, codec Codec, obj any, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(methodQuery, addr).Encode(codec, obj).Apply(opts...).Do(ctx)
+}
+
+// Decode decodes the response body into dst with the codec of the response Content-Type.
+// It accepts the results of a request call as is, so it can be chained with any of the Client methods.
+// The response body is always drained and closed.
+// Responses with non-2xx status codes are reported as *StatusError.
+// An empty response body leaves dst unchanged, otherwise responses without a codec
+// are reported as ErrUnsupportedMediaType.
+func (client *Client) Decode(resp *http.Response, errDo error, dst any) error {
+	return decodeBody(resp, errDo, dst, client.codec, isSuccess)
+}
+
+// GetAs makes a GET request to the given address and decodes the response into a value of type T
+// with the codec of the response Content-Type. See Client.Decode.
+func GetAs[T any](ctx context.Context, client *Client, addr string, opts ...RequestOption) (T, error) {
+	resp, errDo := client.Get(ctx, addr, opts...)
+	return DecodeAs[T](client, resp, errDo)
+}
+
+// DeleteAs makes a DELETE request to the given address and decodes the response into a value of type T
+// with the codec of the response Content-Type. See Client.Decode.
+func DeleteAs[T any](ctx context.Context, client *Client, addr string, opts ...RequestOption) (T, error) {
+	resp, errDo := client.Delete(ctx, addr, opts...)
+	return DecodeAs[T](client, resp, errDo)
+}
+
+// DoAs makes a request with the given method and the body encoded by the codec
+// and decodes the response into a value of type Resp with the codec of the response Content-Type.
+// See Client.Decode.
+func DoAs[Req, Resp any](ctx context.Context, client *Client, method, addr string, codec Codec, obj Req, opts ...RequestOption) (Resp, error) {
+	resp, errDo := client.NewRequest(method, addr).Encode(codec, obj).Apply(opts...).Do(ctx)
+	return DecodeAs[Resp](client, resp, errDo)
+}
+
+// DecodeAs decodes the response body into a value of type T
+// with the codec of the response Content-Type. See Client.Decode.
+func DecodeAs[T any](client *Client, resp *http.Response, errDo error) (T, error) {
+	var value T
+	err := client.Decode(resp, errDo, &value)
+	return value, err
+}
+
+// withCodec ignores the response Content-Type.
+func withCodec(codec Codec) func(contentType string) (Codec, error) {
+	return func(string) (Codec, error) {
+		return codec, nil
+	}
+}
+
+// decodeBody decodes the response body into dst, responses with status codes which are not accepted are reported as *StatusError.
+func decodeBody(resp *http.Response, errDo error, dst any, codecFor func(contentType string) (Codec, error), accept func(statusCode int) bool) error {
+	if errDo != nil {
+		if resp != nil {
+			drainAndClose(resp.Body)
+		}
+		return errDo
+	}
+	defer drainAndClose(resp.Body)
+
+	if !accept(resp.StatusCode) {
+		return newStatusError(resp)
+	}
+
+	body := bufio.NewReader(resp.Body)
+	if _, errPeek := body.Peek(1); errors.Is(errPeek, io.EOF) {
+		return nil
+	}
+
+	contentType := resp.Header.Get(headerContentType)
+	codec, errCodec := codecFor(contentType)
+	if errCodec != nil {
+		return errCodec
+	}
+
+	var errDecode error
+	_, params, _ := mime.ParseMediaType(contentType)
+	decoder, isCharsetDecoder := codec.(charsetDecoder)
+	if charset, ok := params["charset"]; ok && isCharsetDecoder {
+		errDecode = decoder.decodeCharset(body, charset, dst)
+	} else {
+		errDecode = codec.Decode(body, dst)
+	}
+
+	switch {
+	case errors.Is(errDecode, io.EOF):
+		return nil
+	case errDecode != nil:
+		return fmt.Errorf("decode %s response: %w", codec.MediaType(), errDecode)
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ninedraft/httpclient"
+)
+
+// linesCodec stands in for a third-party codec like CBOR.
+// It encodes a slice of strings as lines of text.
+type linesCodec struct{}
+
+var _ httpclient.Codec = linesCodec{}
+
+func (linesCodec) MediaType() string { return "text/x-lines" }
+
+func (codec linesCodec) Marshal(v any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := codec.Encode(buf, v)
+	return buf.Bytes(), err
+}
+
+func (codec linesCodec) Unmarshal(data []byte, v any) error {
+	return codec.Decode(bytes.NewReader(data), v)
+}
+
+func (linesCodec) Encode(w io.Writer, v any) error {
+	lines, ok := v.([]string)
+	if !ok {
+		return errors.New("lines codec: unsupported type")
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\n"))
+	return err
+}
+
+func (linesCodec) Decode(r io.Reader, v any) error {
+	lines, ok := v.(*[]string)
+	if !ok {
+		return errors.New("lines codec: unsupported type")
+	}
+	data, err := io.ReadAll(r)
+	*lines = strings.Split(string(data), "\n")
+	return err
+}
+
+var methodsAs = map[string]func(client *httpclient.Client, ctx context.Context, addr string, codec httpclient.Codec, obj any, opts ...httpclient.RequestOption) (*http.Response, error){
+	http.MethodPost:  (*httpclient.Client).PostAs,
+	http.MethodPut:   (*httpclient.Client).PutAs,
+	http.MethodPatch: (*httpclient.Client).PatchAs,
+	MethodQuery:      (*httpclient.Client).QueryAs,
+}
+
+func TestClient_As(t *testing.T) {
+	for method, call := range methodsAs {
+		method, call := method, call
+		t.Run(method, func(t *testing.T) {
+			t.Parallel()
+
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				assertEqual(t, method, r.Method, "http method")
+				assertEqual(t, "text/x-lines", r.Header.Get("Content-Type"), "content-type")
+				assertEqual(t, "foo\nbar", readString(t, r.Body), "request body")
+
+				w.WriteHeader(http.StatusOK)
+			})
+			defer server.Assert(t)
+
+			client := httpclient.NewFrom(server.Client())
+
+			resp, errCall := call(client, context.Background(), server.URL, linesCodec{}, []string{"foo", "bar"})
+			requireEqual(t, nil, errCall, "call error")
+			defer resp.Body.Close()
+
+			assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+		})
+	}
+}
+
+func TestClient_AsEncodeError(t *testing.T) {
+	t.Parallel()
+
+	client := httpclient.NewFrom(httpclient.DoerFunc(func(*http.Request) (*http.Response, error) {
+		t.Error("doer must not be called")
+		return nil, nil
+	}))
+
+	_, errCall := client.PostAs(context.Background(), "http://example.com", linesCodec{}, 42)
+
+	assertNotEqual(t, nil, errCall, "call error")
+}
+
+func TestGetAs(t *testing.T) {
+	t.Parallel()
+
+	type response struct {
+		Foo string `json:"foo" xml:"foo"`
+	}
+
+	tcs := []struct {
+		contentType string
+		body        string
+	}{
+		{contentType: "application/json", body: `{"foo": "bar"}`},
+		{contentType: "application/problem+json; charset=utf-8", body: `{"foo": "bar"}`},
+		{contentType: "application/xml", body: `<response><foo>bar</foo></response>`},
+		{contentType: "text/xml; charset=iso-8859-1", body: `<response><foo>bar</foo></response>`},
+		{contentType: "application/atom+xml", body: `<response><foo>bar</foo></response>`},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.contentType, func(t *testing.T) {
+			t.Parallel()
+
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				_, _ = io.WriteString(w, tc.body)
+			})
+			defer server.Assert(t)
+
+			client := httpclient.NewFrom(server.Client())
+
+			got, errCall := httpclient.GetAs[response](context.Background(), client, server.URL)
+
+			requireEqual(t, nil, errCall, "call error")
+			assertEqual(t, response{Foo: "bar"}, got, "response")
+		})
+	}
+}
+
+func TestDeleteAs_RegisteredCodec(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, http.MethodDelete, r.Method, "http method")
+		w.Header().Set("Content-Type", "application/vnd.lines")
+		_, _ = io.WriteString(w, "foo\nbar")
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.RegisterCodec(linesCodec{}, "application/vnd.lines")
+
+	got, errCall := httpclient.DeleteAs[[]string](context.Background(), client, server.URL)
+
+	requireEqual(t, nil, errCall, "call error")
+	assertEqualSlices(t, []string{"foo", "bar"}, got, "response")
+}
+
+func TestDoAs(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		_, _ = io.Copy(w, r.Body)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.RegisterCodec(linesCodec{})
+
+	got, errCall := httpclient.DoAs[[]string, []string](context.Background(), client, http.MethodPost, server.URL,
+		linesCodec{}, []string{"foo", "bar"})
+
+	requireEqual(t, nil, errCall, "call error")
+	assertEqualSlices(t, []string{"foo", "bar"}, got, "response")
+}
+
+func TestGetAs_UnsupportedMediaType(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/cbor")
+		_, _ = io.WriteString(w, "\xa1")
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+
+	_, errCall := httpclient.GetAs[map[string]string](context.Background(), client, server.URL)
+
+	assertEqual(t, true, errors.Is(errCall, httpclient.ErrUnsupportedMediaType), "expected unsupported media type, got %v", errCall)
+}
+
+func TestGetAs_EmptyBody(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+
+	got, errCall := httpclient.GetAs[map[string]string](context.Background(), client, server.URL)
+
+	requireEqual(t, nil, errCall, "call error")
+	assertEqual(t, 0, len(got), "response")
+}
+
+func TestGetAs_StatusError(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+
+	_, errCall := httpclient.GetAs[map[string]string](context.Background(), client, server.URL)
+
+	var statusErr *httpclient.StatusError
+	requireEqual(t, true, errors.As(errCall, &statusErr), "expected status error, got %v", errCall)
+	assertEqual(t, http.StatusNotFound, statusErr.StatusCode, "status code")
+}
+
+func TestRequest_Encode(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, "text/x-lines", r.Header.Get("Content-Type"), "content type")
+		w.Header().Set("Content-Type", "text/x-lines; charset=utf-8")
+		_, _ = io.Copy(w, r.Body)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.RegisterCodec(linesCodec{})
+
+	var got []string
+	errCall := client.NewRequest(http.MethodPost, server.URL).
+		Encode(linesCodec{}, []string{"foo", "bar"}).
+		Decode(context.Background(), &got)
+
+	requireEqual(t, nil, errCall, "call error")
+	assertEqualSlices(t, []string{"foo", "bar"}, got, "response")
+}
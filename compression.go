@@ -0,0 +1,386 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Decoder returns a reader of the decoded content.
+// Brotli and zstd are not built in, so the module has no third-party dependencies.
+// Their decoders are plugged in with CompressionOptions.Decoders and then advertised first:
+//
+//	"br": func(r io.Reader) (io.ReadCloser, error) {
+//		return io.NopCloser(brotli.NewReader(r)), nil
+//	},
+//	"zstd": func(r io.Reader) (io.ReadCloser, error) {
+//		decoder, err := zstd.NewReader(r)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return decoder.IOReadCloser(), nil
+//	},
+type Decoder func(r io.Reader) (io.ReadCloser, error)
+
+// Encoder returns a writer which encodes the content to w.
+// Closing the writer must flush the encoded content, but not close w.
+type Encoder func(w io.Writer) (io.WriteCloser, error)
+
+// DefaultMinCompressSize is the default size of request bodies starting from which they are compressed.
+const DefaultMinCompressSize = 1 << 10
+
+// DefaultMaxCompressBufferSize is the default size of rewindable request bodies up to which they are compressed in memory.
+const DefaultMaxCompressBufferSize = 1 << 20
+
+var errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// CompressionOptions configures the Compression wrapper.
+type CompressionOptions struct {
+	// Decoders of content codings in addition to the built-in gzip and deflate.
+	Decoders map[string]Decoder
+
+	// RequestEncoding enables compression of request bodies with the given content coding.
+	// Built-in codings are gzip and deflate, others are added with Encoders.
+	RequestEncoding string
+
+	// Encoders of content codings in addition to the built-in gzip and deflate.
+	Encoders map[string]Encoder
+
+	// MinSize is the size of request bodies starting from which they are compressed.
+	// Bodies of unknown size are always compressed. Default is DefaultMinCompressSize.
+	MinSize int64
+
+	// MaxBufferSize is the size of rewindable request bodies up to which they are compressed in memory.
+	// Larger bodies and bodies of unknown size are compressed on the fly, every time they are rewound.
+	// Default is DefaultMaxCompressBufferSize.
+	MaxBufferSize int64
+}
+
+func (opts CompressionOptions) withDefaults() CompressionOptions {
+	decoders := map[string]Decoder{
+		"gzip":    decodeGzip,
+		"x-gzip":  decodeGzip,
+		"deflate": decodeDeflate,
+	}
+	for coding, decoder := range opts.Decoders {
+		decoders[strings.ToLower(coding)] = decoder
+	}
+	opts.Decoders = decoders
+
+	encoders := map[string]Encoder{
+		"gzip":    encodeGzip,
+		"deflate": encodeDeflate,
+	}
+	for coding, encoder := range opts.Encoders {
+		encoders[strings.ToLower(coding)] = encoder
+	}
+	opts.Encoders = encoders
+
+	opts.RequestEncoding = strings.ToLower(opts.RequestEncoding)
+	if opts.MinSize <= 0 {
+		opts.MinSize = DefaultMinCompressSize
+	}
+	if opts.MaxBufferSize <= 0 {
+		opts.MaxBufferSize = DefaultMaxCompressBufferSize
+	}
+
+	return opts
+}
+
+// Compression creates a wrapper which compresses request bodies and decodes responses.
+//
+// If a request has no Accept-Encoding header, the wrapper advertises the supported codings
+// and decodes the response body. Such responses have no Content-Encoding and Content-Length headers
+// and have http.Response.Uncompressed set. Decoding errors are returned when the body is read.
+// Requests with Accept-Encoding set by the caller are passed as is.
+//
+// Request bodies which already have a Content-Encoding header are sent as is.
+// Rewindable bodies up to MaxBufferSize are compressed in memory and are sent uncompressed if that is not smaller.
+// Other bodies are compressed on the fly, rewindable ones can still be retried.
+func Compression(opts CompressionOptions) Wrapper {
+	opts = opts.withDefaults()
+	acceptEncoding := acceptEncodingValue(opts.Decoders)
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return opts.do(next, req, acceptEncoding)
+		})
+	}
+}
+
+func (opts CompressionOptions) do(next Doer, req *http.Request, acceptEncoding string) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	// zero length of a request with a body means unknown length
+	if opts.RequestEncoding != "" && hasBody(req) && req.Header.Get("Content-Encoding") == "" &&
+		(req.ContentLength <= 0 || req.ContentLength >= opts.MinSize) {
+		if errCompress := opts.compressRequest(req); errCompress != nil {
+			return nil, fmt.Errorf("compress request: %w", errCompress)
+		}
+	}
+
+	decode := req.Header.Get("Accept-Encoding") == ""
+	if decode {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	resp, err := next.Do(req)
+	if err != nil {
+		// wrappers which fail early may leave the body unclosed
+		if body, ok := req.Body.(*encodedBody); ok {
+			_ = body.Close()
+		}
+		return resp, err
+	}
+	if !decode {
+		return resp, nil
+	}
+
+	opts.decodeResponse(resp)
+	return resp, nil
+}
+
+func (opts CompressionOptions) compressRequest(req *http.Request) error {
+	encoder, ok := opts.Encoders[opts.RequestEncoding]
+	if !ok {
+		return fmt.Errorf("%w: %s", errUnsupportedEncoding, opts.RequestEncoding)
+	}
+
+	getBody := req.GetBody
+	if getBody != nil && req.ContentLength > 0 && req.ContentLength <= opts.MaxBufferSize {
+		return opts.compressBuffered(req, encoder)
+	}
+
+	req.Body = &encodedBody{body: req.Body, encoder: encoder}
+	if getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return &encodedBody{body: body, encoder: encoder}, nil
+		}
+	}
+	req.ContentLength = -1
+	req.Header.Set("Content-Encoding", opts.RequestEncoding)
+	return nil
+}
+
+// compressBuffered compresses the body in memory, so the length of the compressed body is known.
+func (opts CompressionOptions) compressBuffered(req *http.Request, encoder Encoder) error {
+	original, errRead := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if errRead != nil {
+		return errRead
+	}
+
+	compressed := &bytes.Buffer{}
+	writer, errEncoder := encoder(compressed)
+	if errEncoder != nil {
+		return errEncoder
+	}
+	if _, errWrite := writer.Write(original); errWrite != nil {
+		return errWrite
+	}
+	if errClose := writer.Close(); errClose != nil {
+		return errClose
+	}
+
+	body := original
+	if compressed.Len() < len(original) {
+		body = compressed.Bytes()
+		req.Header.Set("Content-Encoding", opts.RequestEncoding)
+	}
+
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return nil
+}
+
+// encodedBody compresses the body on the fly.
+// The encoder starts on the first Read, so a request which is never sent does not leak it.
+type encodedBody struct {
+	body    io.ReadCloser
+	encoder Encoder
+
+	once   sync.Once
+	reader *io.PipeReader
+}
+
+func (body *encodedBody) Read(p []byte) (int, error) {
+	body.once.Do(body.start)
+	return body.reader.Read(p)
+}
+
+func (body *encodedBody) start() {
+	reader, writer := io.Pipe()
+	body.reader = reader
+
+	go body.encode(writer)
+}
+
+func (body *encodedBody) encode(writer *io.PipeWriter) {
+	defer body.body.Close()
+
+	encoded, err := body.encoder(writer)
+	if err == nil {
+		_, err = io.Copy(encoded, body.body)
+		err = errors.Join(err, encoded.Close())
+	}
+	writer.CloseWithError(err)
+}
+
+// Close stops the encoder, which closes the original body, or closes it directly if the encoder is not started.
+func (body *encodedBody) Close() error {
+	started := true
+	body.once.Do(func() {
+		started = false
+		reader, _ := io.Pipe()
+		_ = reader.Close()
+		body.reader = reader
+	})
+
+	if !started {
+		return body.body.Close()
+	}
+	return body.reader.Close()
+}
+
+func (opts CompressionOptions) decodeResponse(resp *http.Response) {
+	codings := contentCodings(resp.Header)
+	if len(codings) == 0 || resp.Body == nil || resp.Body == http.NoBody {
+		return
+	}
+
+	decoders := make([]Decoder, 0, len(codings))
+	for _, coding := range codings {
+		decoder, ok := opts.Decoders[coding]
+		if !ok {
+			return
+		}
+		decoders = append(decoders, decoder)
+	}
+
+	resp.Body = &decodedBody{body: resp.Body, decoders: decoders}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// contentCodings returns the content codings of the response without identity.
+func contentCodings(header http.Header) []string {
+	var codings []string
+	for _, value := range header.Values("Content-Encoding") {
+		for _, coding := range strings.Split(value, ",") {
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding != "" && coding != "identity" {
+				codings = append(codings, coding)
+			}
+		}
+	}
+	return codings
+}
+
+// decodedBody lazily creates the decoders on the first read,
+// so decoding errors are reported by Read.
+type decodedBody struct {
+	body     io.ReadCloser
+	decoders []Decoder
+	reader   io.Reader
+	closers  []io.Closer
+	err      error
+}
+
+func (body *decodedBody) Read(p []byte) (int, error) {
+	if body.reader == nil && body.err == nil {
+		body.init()
+	}
+	if body.err != nil {
+		return 0, body.err
+	}
+	return body.reader.Read(p)
+}
+
+func (body *decodedBody) init() {
+	var reader io.Reader = body.body
+	// codings are listed in the order they were applied
+	for i := len(body.decoders) - 1; i >= 0; i-- {
+		decoded, errDecoder := body.decoders[i](reader)
+		if errDecoder != nil {
+			body.err = fmt.Errorf("decode response body: %w", errDecoder)
+			return
+		}
+		body.closers = append(body.closers, decoded)
+		reader = decoded
+	}
+	body.reader = reader
+}
+
+func (body *decodedBody) Close() error {
+	errs := make([]error, 0, len(body.closers)+1)
+	for i := len(body.closers) - 1; i >= 0; i-- {
+		errs = append(errs, body.closers[i].Close())
+	}
+	errs = append(errs, body.body.Close())
+	return errors.Join(errs...)
+}
+
+// acceptEncodingValue lists the codings with decoders, the most efficient ones first.
+func acceptEncodingValue(decoders map[string]Decoder) string {
+	preferred := map[string]int{"zstd": 1, "br": 2, "gzip": 3, "deflate": 4}
+
+	codings := make([]string, 0, len(decoders))
+	for coding := range decoders {
+		if coding != "x-gzip" {
+			codings = append(codings, coding)
+		}
+	}
+	sort.Slice(codings, func(i, j int) bool {
+		pi, pj := preferred[codings[i]], preferred[codings[j]]
+		if pi == 0 || pj == 0 {
+			if pi != pj {
+				return pj == 0
+			}
+			return codings[i] < codings[j]
+		}
+		return pi < pj
+	})
+
+	return strings.Join(codings, ", ")
+}
+
+func decodeGzip(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// decodeDeflate decodes the zlib format of the deflate coding,
+// falling back to raw deflate streams sent by some servers.
+func decodeDeflate(r io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+	header, _ := buffered.Peek(2)
+	if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(buffered)
+	}
+	return flate.NewReader(buffered), nil
+}
+
+func encodeGzip(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func encodeDeflate(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriter(w), nil
+}
@@ -0,0 +1,343 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ninedraft/httpclient"
+)
+
+// base64Decoder stands in for a third-party decoder like brotli.
+func base64Decoder(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(base64.NewDecoder(base64.StdEncoding, r)), nil
+}
+
+func gzipData(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	writer := gzip.NewWriter(buf)
+	_, _ = writer.Write(data)
+	requireEqual(t, nil, writer.Close(), "gzip")
+	return buf.Bytes()
+}
+
+func gunzip(t *testing.T, r io.Reader) string {
+	t.Helper()
+
+	reader, err := gzip.NewReader(r)
+	requireEqual(t, nil, err, "gzip reader")
+	return readString(t, reader)
+}
+
+func TestCompression_Response(t *testing.T) {
+	t.Parallel()
+
+	const text = "hello, compressed world"
+
+	zlibData := &bytes.Buffer{}
+	zlibWriter := zlib.NewWriter(zlibData)
+	_, _ = io.WriteString(zlibWriter, text)
+	_ = zlibWriter.Close()
+
+	rawDeflateData := &bytes.Buffer{}
+	flateWriter, _ := flate.NewWriter(rawDeflateData, flate.DefaultCompression)
+	_, _ = io.WriteString(flateWriter, text)
+	_ = flateWriter.Close()
+
+	tcs := []struct {
+		name     string
+		encoding string
+		body     func(t *testing.T) []byte
+	}{
+		{name: "gzip", encoding: "gzip", body: func(t *testing.T) []byte { return gzipData(t, []byte(text)) }},
+		{name: "deflate", encoding: "deflate", body: func(*testing.T) []byte { return zlibData.Bytes() }},
+		{name: "raw deflate", encoding: "deflate", body: func(*testing.T) []byte { return rawDeflateData.Bytes() }},
+		{name: "plugged decoder", encoding: "br", body: func(*testing.T) []byte {
+			return []byte(base64.StdEncoding.EncodeToString([]byte(text)))
+		}},
+		{name: "several codings", encoding: "gzip, br", body: func(t *testing.T) []byte {
+			return []byte(base64.StdEncoding.EncodeToString(gzipData(t, []byte(text))))
+		}},
+		{name: "identity", encoding: "identity", body: func(*testing.T) []byte { return []byte(text) }},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				assertEqual(t, "br, gzip, deflate", r.Header.Get("Accept-Encoding"), "accept encoding")
+				w.Header().Set("Content-Encoding", tc.encoding)
+				_, _ = w.Write(tc.body(t))
+			})
+			defer server.Assert(t)
+
+			client := httpclient.NewFrom(server.Client())
+			client.Use(httpclient.Compression(httpclient.CompressionOptions{
+				Decoders: map[string]httpclient.Decoder{"br": base64Decoder},
+			}))
+
+			resp, err := client.Get(context.Background(), server.URL)
+			requireEqual(t, nil, err, "call error")
+			defer resp.Body.Close()
+
+			assertEqual(t, text, readString(t, resp.Body), "decoded body")
+			if tc.encoding != "identity" {
+				assertEqual(t, "", resp.Header.Get("Content-Encoding"), "content encoding")
+				assertEqual(t, true, resp.Uncompressed, "uncompressed")
+				assertEqual(t, -1, resp.ContentLength, "content length")
+			}
+		})
+	}
+}
+
+func TestCompression_PluggedDecoders(t *testing.T) {
+	t.Parallel()
+
+	const text = "hello, plugged decoders"
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, "zstd, br, gzip, deflate, x-custom", r.Header.Get("Accept-Encoding"), "accept encoding")
+		// applied in order: gzip, then zstd, then br
+		w.Header().Set("Content-Encoding", "gzip, zstd, br")
+		encoded := base64.StdEncoding.EncodeToString(gzipData(t, []byte(text)))
+		_, _ = io.WriteString(w, base64.StdEncoding.EncodeToString([]byte(encoded)))
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Compression(httpclient.CompressionOptions{
+		Decoders: map[string]httpclient.Decoder{
+			"x-custom": base64Decoder,
+			"br":       base64Decoder,
+			"zstd":     base64Decoder,
+		},
+	}))
+
+	resp, err := client.Get(context.Background(), server.URL)
+	requireEqual(t, nil, err, "call error")
+	defer resp.Body.Close()
+
+	assertEqual(t, text, readString(t, resp.Body), "decoded body")
+	assertEqual(t, "", resp.Header.Get("Content-Encoding"), "content encoding")
+}
+
+func TestCompression_ResponseAsIs(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "zstd")
+		_, _ = io.WriteString(w, "zstd data")
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Compression(httpclient.CompressionOptions{}))
+
+	resp, err := client.Get(context.Background(), server.URL)
+	requireEqual(t, nil, err, "call error")
+	defer resp.Body.Close()
+
+	assertEqual(t, "zstd data", readString(t, resp.Body), "body of unsupported coding")
+	assertEqual(t, "zstd", resp.Header.Get("Content-Encoding"), "content encoding")
+}
+
+func TestCompression_CallerAcceptEncoding(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, "gzip", r.Header.Get("Accept-Encoding"), "accept encoding")
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write(gzipData(t, []byte("raw")))
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Compression(httpclient.CompressionOptions{}))
+
+	resp, err := client.Get(context.Background(), server.URL, httpclient.WithHeader("Accept-Encoding", "gzip"))
+	requireEqual(t, nil, err, "call error")
+	defer resp.Body.Close()
+
+	assertEqual(t, "gzip", resp.Header.Get("Content-Encoding"), "content encoding")
+	assertEqual(t, "raw", gunzip(t, resp.Body), "body is not decoded")
+}
+
+func TestCompression_InvalidBody(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = io.WriteString(w, "not gzip")
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Compression(httpclient.CompressionOptions{}))
+
+	resp, err := client.Get(context.Background(), server.URL)
+	requireEqual(t, nil, err, "call error")
+	defer resp.Body.Close()
+
+	_, errRead := io.ReadAll(resp.Body)
+	assertNotEqual(t, nil, errRead, "read error")
+}
+
+func TestCompression_Request(t *testing.T) {
+	t.Parallel()
+
+	large := strings.Repeat("compressible ", 200)
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			assertEqual(t, "gzip", r.Header.Get("Content-Encoding"), "content encoding")
+			assertNotEqual(t, -1, r.ContentLength, "content length")
+			assertEqual(t, `"`+large+`"`, gunzip(t, r.Body), "decompressed body")
+		case "/small":
+			assertEqual(t, "", r.Header.Get("Content-Encoding"), "content encoding")
+			assertEqual(t, "name=gopher", readString(t, r.Body), "body")
+		case "/stream":
+			assertEqual(t, "gzip", r.Header.Get("Content-Encoding"), "content encoding")
+			assertEqual(t, -1, r.ContentLength, "content length")
+			assertEqual(t, true, strings.Contains(gunzip(t, r.Body), large), "multipart body")
+		}
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Compression(httpclient.CompressionOptions{RequestEncoding: "gzip"}))
+
+	resp, err := client.PostJSON(context.Background(), server.URL+"/large", large)
+	requireEqual(t, nil, err, "call error")
+	resp.Body.Close()
+
+	resp, err = client.PostForm(context.Background(), server.URL+"/small", map[string][]string{"name": {"gopher"}})
+	requireEqual(t, nil, err, "call error")
+	resp.Body.Close()
+
+	resp, err = client.PostMultipart(context.Background(), server.URL+"/stream",
+		httpclient.MultipartFile("file", "data.txt", strings.NewReader(large)))
+	requireEqual(t, nil, err, "call error")
+	resp.Body.Close()
+}
+
+func TestCompression_RequestRetry(t *testing.T) {
+	t.Parallel()
+
+	server, calls := flakyServer(t, 1, http.StatusServiceUnavailable, nil)
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(
+		httpclient.Retry(httpclient.RetryOptions{BaseDelay: time.Millisecond}),
+		httpclient.Compression(httpclient.CompressionOptions{RequestEncoding: "deflate", MinSize: 1}),
+	)
+
+	body := strings.Repeat("a", 100)
+	resp, err := client.Put(context.Background(), server.URL, "text/plain", strings.NewReader(body))
+	requireEqual(t, nil, err, "call error")
+	defer resp.Body.Close()
+
+	reader, errReader := zlib.NewReader(resp.Body)
+	requireEqual(t, nil, errReader, "zlib reader")
+	assertEqual(t, body, readString(t, reader), "echoed body")
+	assertEqual(t, 2, calls.Load(), "calls")
+}
+
+func TestCompression_RequestRetryStream(t *testing.T) {
+	t.Parallel()
+
+	body := strings.Repeat("a", 100)
+	calls := &atomic.Int32{}
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, "deflate", r.Header.Get("Content-Encoding"), "content encoding")
+		assertEqual(t, -1, r.ContentLength, "content length")
+
+		reader, errReader := zlib.NewReader(r.Body)
+		requireEqual(t, nil, errReader, "zlib reader")
+		assertEqual(t, body, readString(t, reader), "decompressed body")
+
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(
+		httpclient.Retry(httpclient.RetryOptions{BaseDelay: time.Millisecond}),
+		httpclient.Compression(httpclient.CompressionOptions{RequestEncoding: "deflate", MinSize: 1, MaxBufferSize: 16}),
+	)
+
+	resp, err := client.Put(context.Background(), server.URL, "text/plain", strings.NewReader(body))
+	requireEqual(t, nil, err, "call error")
+	defer resp.Body.Close()
+
+	assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+	assertEqual(t, 2, calls.Load(), "calls")
+}
+
+func TestCompression_UnsupportedRequestEncoding(t *testing.T) {
+	t.Parallel()
+
+	client := httpclient.NewFrom(httpclient.DoerFunc(func(*http.Request) (*http.Response, error) {
+		t.Error("request is sent")
+		return nil, nil
+	}))
+	client.Use(httpclient.Compression(httpclient.CompressionOptions{RequestEncoding: "zstd", MinSize: 1}))
+
+	_, err := client.Post(context.Background(), "http://example.com", "text/plain", strings.NewReader("data"))
+	assertNotEqual(t, nil, err, "call error")
+}
+
+func TestCompression_RequestStreamNotSent(t *testing.T) {
+	t.Parallel()
+
+	errEarly := errors.New("rejected before sending")
+	body := &closeTracker{Reader: io.MultiReader(strings.NewReader(strings.Repeat("a", 1<<20)))}
+
+	client := httpclient.NewFrom(httpclient.DoerFunc(func(*http.Request) (*http.Response, error) {
+		t.Error("request is sent")
+		return nil, nil
+	}))
+	client.Use(
+		httpclient.Compression(httpclient.CompressionOptions{RequestEncoding: "gzip"}),
+		func(httpclient.Doer) httpclient.Doer {
+			return httpclient.DoerFunc(func(*http.Request) (*http.Response, error) {
+				return nil, errEarly
+			})
+		},
+	)
+
+	_, err := client.Post(context.Background(), "http://example.com", "text/plain", body)
+
+	assertEqual(t, true, errors.Is(err, errEarly), "unexpected error: %v", err)
+	assertEqual(t, true, body.closed.Load(), "original body is closed")
+	assertEqual(t, "", leakedGoroutines(time.Second), "leaked goroutines")
+}
+
+type closeTracker struct {
+	io.Reader
+	closed atomic.Bool
+}
+
+func (tracker *closeTracker) Close() error {
+	tracker.closed.Store(true)
+	return nil
+}
@@ -0,0 +1,373 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DigestAlgorithm is a hash algorithm of the Content-Digest and Repr-Digest fields (RFC 9530).
+type DigestAlgorithm string
+
+const (
+	DigestSHA256 DigestAlgorithm = "sha-256"
+	DigestSHA512 DigestAlgorithm = "sha-512"
+)
+
+func (algorithm DigestAlgorithm) newHash() hash.Hash {
+	switch algorithm {
+	case DigestSHA256:
+		return sha256.New()
+	case DigestSHA512:
+		return sha512.New()
+	default:
+		return nil
+	}
+}
+
+// ErrUnsupportedDigest is returned when a digest field contains no supported algorithm.
+var ErrUnsupportedDigest = errors.New("no supported digest algorithm")
+
+// DigestMismatchError is returned when the content doesn't match its digest.
+type DigestMismatchError struct {
+	// Field is the name of the digest field, Content-Digest or Repr-Digest.
+	Field     string
+	Algorithm DigestAlgorithm
+	Expected  []byte
+	Actual    []byte
+}
+
+func (err *DigestMismatchError) Error() string {
+	return fmt.Sprintf("%s %s mismatch: expected %s, got %s", err.Field, err.Algorithm,
+		base64.StdEncoding.EncodeToString(err.Expected), base64.StdEncoding.EncodeToString(err.Actual))
+}
+
+// ContentDigestOptions configures the ContentDigest wrapper.
+type ContentDigestOptions struct {
+	// Algorithms are used to compute digests of request bodies. Default is sha-256.
+	Algorithms []DigestAlgorithm
+
+	// SkipRequests disables digests of request bodies.
+	SkipRequests bool
+
+	// SkipResponses disables verification of response digests.
+	SkipResponses bool
+}
+
+// ContentDigest creates a wrapper which adds the Content-Digest field to requests
+// and verifies the Content-Digest and Repr-Digest fields of responses (RFC 9530).
+//
+// Digests of rewindable request bodies are computed using http.Request.GetBody before sending.
+// Streaming bodies, e.g. multipart ones, are hashed while they are sent
+// and the digest is sent in the request trailer.
+//
+// Response bodies are verified as they are read: the last Read returns a *DigestMismatchError
+// instead of io.EOF if the content doesn't match. Digests sent in response trailers are verified too.
+// Repr-Digest is verified only for complete responses without Content-Encoding,
+// when the representation is the same as the content.
+func ContentDigest(opts ContentDigestOptions) Wrapper {
+	if len(opts.Algorithms) == 0 {
+		opts.Algorithms = []DigestAlgorithm{DigestSHA256}
+	}
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return opts.do(next, req)
+		})
+	}
+}
+
+func (opts ContentDigestOptions) do(next Doer, req *http.Request) (*http.Response, error) {
+	if !opts.SkipRequests && hasBody(req) && req.Header.Get("Content-Digest") == "" {
+		var errDigest error
+		req, errDigest = opts.digestRequest(req)
+		if errDigest != nil {
+			return nil, errDigest
+		}
+	}
+
+	resp, err := next.Do(req)
+	if err != nil || opts.SkipResponses {
+		return resp, err
+	}
+
+	verifyResponseDigest(resp)
+
+	return resp, nil
+}
+
+func (opts ContentDigestOptions) digestRequest(req *http.Request) (*http.Request, error) {
+	req = req.Clone(req.Context())
+
+	if req.GetBody != nil {
+		body, errBody := req.GetBody()
+		if errBody != nil {
+			return nil, fmt.Errorf("content digest: %w", errBody)
+		}
+		defer body.Close()
+
+		value, errDigest := DigestValue(body, opts.Algorithms...)
+		if errDigest != nil {
+			return nil, fmt.Errorf("content digest: %w", errDigest)
+		}
+		req.Header.Set("Content-Digest", value)
+
+		return req, nil
+	}
+
+	if req.Trailer == nil {
+		req.Trailer = http.Header{}
+	}
+	req.Trailer["Content-Digest"] = nil
+	req.Body = &trailerDigestBody{
+		body:    req.Body,
+		hashes:  newDigestHashes(opts.Algorithms),
+		trailer: req.Trailer,
+	}
+
+	return req, nil
+}
+
+// trailerDigestBody sets the Content-Digest trailer when the body is read to the end.
+type trailerDigestBody struct {
+	body    io.ReadCloser
+	hashes  digestHashes
+	trailer http.Header
+}
+
+func (body *trailerDigestBody) Read(p []byte) (int, error) {
+	n, err := body.body.Read(p)
+	body.hashes.write(p[:n])
+	if err == io.EOF {
+		body.trailer.Set("Content-Digest", body.hashes.value())
+	}
+	return n, err
+}
+
+func (body *trailerDigestBody) Close() error {
+	return body.body.Close()
+}
+
+func verifyResponseDigest(resp *http.Response) {
+	if resp.Body == nil || resp.Body == http.NoBody || resp.Uncompressed {
+		return
+	}
+
+	fields := []string{"Content-Digest"}
+	if resp.StatusCode == http.StatusOK && resp.Header.Get("Content-Encoding") == "" {
+		fields = append(fields, "Repr-Digest")
+	}
+
+	var checks []*digestCheck
+	for _, field := range fields {
+		if values := resp.Header.Values(field); len(values) > 0 {
+			checks = append(checks, newDigestCheck(field, values))
+			continue
+		}
+		if _, ok := resp.Trailer[field]; ok {
+			// the values are known only when the body is read
+			checks = append(checks, &digestCheck{field: field, hashes: newDigestHashes([]DigestAlgorithm{DigestSHA256, DigestSHA512})})
+		}
+	}
+
+	if len(checks) > 0 {
+		resp.Body = &digestBody{body: resp.Body, checks: checks, trailer: resp}
+	}
+}
+
+// digestBody verifies the digests of the response body when it is read to the end.
+type digestBody struct {
+	body    io.ReadCloser
+	checks  []*digestCheck
+	trailer *http.Response
+
+	mu  sync.Mutex
+	err error
+}
+
+func (body *digestBody) Read(p []byte) (int, error) {
+	body.mu.Lock()
+	defer body.mu.Unlock()
+
+	if body.err != nil {
+		return 0, body.err
+	}
+
+	n, err := body.body.Read(p)
+	for _, check := range body.checks {
+		check.hashes.write(p[:n])
+	}
+
+	if err == io.EOF {
+		for _, check := range body.checks {
+			if errCheck := check.verify(body.trailer.Trailer); errCheck != nil {
+				err = errCheck
+				break
+			}
+		}
+	}
+	if err != nil {
+		body.err = err
+	}
+
+	return n, err
+}
+
+func (body *digestBody) Close() error {
+	return body.body.Close()
+}
+
+// digestCheck verifies a digest field.
+// Checks of trailer fields have no expected values until the end of the body.
+type digestCheck struct {
+	field    string
+	expected map[DigestAlgorithm][]byte
+	hashes   digestHashes
+}
+
+func newDigestCheck(field string, values []string) *digestCheck {
+	expected := parseDigestField(values)
+
+	algorithms := make([]DigestAlgorithm, 0, len(expected))
+	for algorithm := range expected {
+		algorithms = append(algorithms, algorithm)
+	}
+
+	return &digestCheck{field: field, expected: expected, hashes: newDigestHashes(algorithms)}
+}
+
+func (check *digestCheck) verify(trailer http.Header) error {
+	expected := check.expected
+	if expected == nil {
+		expected = parseDigestField(trailer.Values(check.field))
+	}
+
+	for _, h := range check.hashes {
+		want, ok := expected[h.algorithm]
+		if !ok {
+			continue
+		}
+		if got := h.Sum(nil); !bytes.Equal(want, got) {
+			return &DigestMismatchError{Field: check.field, Algorithm: h.algorithm, Expected: want, Actual: got}
+		}
+	}
+	return nil
+}
+
+// DigestValue returns the digest field value of the content, e.g. "sha-256=:...:".
+// The default algorithm is sha-256.
+func DigestValue(content io.Reader, algorithms ...DigestAlgorithm) (string, error) {
+	if len(algorithms) == 0 {
+		algorithms = []DigestAlgorithm{DigestSHA256}
+	}
+
+	hashes := newDigestHashes(algorithms)
+	if len(hashes) == 0 {
+		return "", ErrUnsupportedDigest
+	}
+
+	buf := make([]byte, 32<<10)
+	for {
+		n, errRead := content.Read(buf)
+		hashes.write(buf[:n])
+		if errRead == io.EOF {
+			return hashes.value(), nil
+		}
+		if errRead != nil {
+			return "", errRead
+		}
+	}
+}
+
+// VerifyDigest verifies the content against the values of the digest field.
+// All the supported algorithms of the field are verified.
+// It returns ErrUnsupportedDigest if the field contains no supported algorithm
+// and a *DigestMismatchError if the content doesn't match.
+func VerifyDigest(field string, values []string, content io.Reader) error {
+	check := newDigestCheck(field, values)
+	if len(check.hashes) == 0 {
+		return fmt.Errorf("%s: %w", field, ErrUnsupportedDigest)
+	}
+
+	buf := make([]byte, 32<<10)
+	for {
+		n, errRead := content.Read(buf)
+		check.hashes.write(buf[:n])
+		if errRead == io.EOF {
+			return check.verify(nil)
+		}
+		if errRead != nil {
+			return errRead
+		}
+	}
+}
+
+type namedHash struct {
+	hash.Hash
+	algorithm DigestAlgorithm
+}
+
+type digestHashes []namedHash
+
+// newDigestHashes creates hashes of the supported algorithms, unsupported ones are skipped.
+func newDigestHashes(algorithms []DigestAlgorithm) digestHashes {
+	var hashes digestHashes
+	for _, algorithm := range algorithms {
+		if h := algorithm.newHash(); h != nil {
+			hashes = append(hashes, namedHash{Hash: h, algorithm: algorithm})
+		}
+	}
+	return hashes
+}
+
+func (hashes digestHashes) write(p []byte) {
+	for _, h := range hashes {
+		h.Write(p)
+	}
+}
+
+// value returns the digest field value.
+func (hashes digestHashes) value() string {
+	members := make([]string, 0, len(hashes))
+	for _, h := range hashes {
+		members = append(members, string(h.algorithm)+"=:"+base64.StdEncoding.EncodeToString(h.Sum(nil))+":")
+	}
+	return strings.Join(members, ", ")
+}
+
+// parseDigestField parses a digest field dictionary, e.g. "sha-256=:...:, sha-512=:...:".
+// Members with unsupported algorithms or invalid values are skipped.
+func parseDigestField(values []string) map[DigestAlgorithm][]byte {
+	digests := map[DigestAlgorithm][]byte{}
+
+	for _, value := range values {
+		for _, member := range strings.Split(value, ",") {
+			name, raw, ok := strings.Cut(strings.TrimSpace(member), "=")
+			if !ok || !strings.HasPrefix(raw, ":") {
+				continue
+			}
+
+			end := strings.IndexByte(raw[1:], ':')
+			if end < 0 {
+				continue
+			}
+
+			algorithm := DigestAlgorithm(strings.ToLower(name))
+			digest, errDecode := base64.StdEncoding.DecodeString(raw[1 : end+1])
+			if errDecode != nil || algorithm.newHash() == nil {
+				continue
+			}
+			digests[algorithm] = digest
+		}
+	}
+
+	return digests
+}
@@ -0,0 +1,190 @@
+package httpclient_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ninedraft/httpclient"
+)
+
+func sha256Digest(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+func TestContentDigest_Request(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body := readString(t, r.Body)
+		assertEqual(t, sha256Digest(body), r.Header.Get("Content-Digest"), "content digest")
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.ContentDigest(httpclient.ContentDigestOptions{}))
+
+	resp, err := client.PostJSON(context.Background(), server.URL, map[string]string{"name": "gopher"})
+	requireEqual(t, nil, err, "call error")
+	resp.Body.Close()
+}
+
+func TestContentDigest_RequestAlgorithms(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body := readString(t, r.Body)
+		sum := sha512.Sum512([]byte(body))
+		expected := sha256Digest(body) + ", sha-512=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+		assertEqual(t, expected, r.Header.Get("Content-Digest"), "content digest")
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.ContentDigest(httpclient.ContentDigestOptions{
+		Algorithms: []httpclient.DigestAlgorithm{httpclient.DigestSHA256, httpclient.DigestSHA512},
+	}))
+
+	resp, err := client.PostForm(context.Background(), server.URL, map[string][]string{"name": {"gopher"}})
+	requireEqual(t, nil, err, "call error")
+	resp.Body.Close()
+}
+
+func TestContentDigest_RequestTrailer(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, "", r.Header.Get("Content-Digest"), "content digest header")
+
+		body := readString(t, r.Body)
+		assertEqual(t, sha256Digest(body), r.Trailer.Get("Content-Digest"), "content digest trailer")
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.ContentDigest(httpclient.ContentDigestOptions{}))
+
+	resp, err := client.PostMultipart(context.Background(), server.URL,
+		httpclient.MultipartFile("file", "hello.txt", strings.NewReader("hello")))
+	requireEqual(t, nil, err, "call error")
+	resp.Body.Close()
+}
+
+func TestContentDigest_Response(t *testing.T) {
+	t.Parallel()
+
+	const body = `{"name":"gopher"}`
+
+	tcs := []struct {
+		name     string
+		respond  func(w http.ResponseWriter)
+		mismatch string
+	}{
+		{
+			name: "valid",
+			respond: func(w http.ResponseWriter) {
+				w.Header().Set("Content-Digest", sha256Digest(body))
+				_, _ = io.WriteString(w, body)
+			},
+		},
+		{
+			name: "no digest",
+			respond: func(w http.ResponseWriter) {
+				_, _ = io.WriteString(w, body)
+			},
+		},
+		{
+			name: "unsupported algorithm",
+			respond: func(w http.ResponseWriter) {
+				w.Header().Set("Content-Digest", "md5=:AAAA:")
+				_, _ = io.WriteString(w, body)
+			},
+		},
+		{
+			name: "content digest mismatch",
+			respond: func(w http.ResponseWriter) {
+				w.Header().Set("Content-Digest", sha256Digest("other"))
+				_, _ = io.WriteString(w, body)
+			},
+			mismatch: "Content-Digest",
+		},
+		{
+			name: "repr digest mismatch",
+			respond: func(w http.ResponseWriter) {
+				w.Header().Set("Repr-Digest", sha256Digest("other"))
+				_, _ = io.WriteString(w, body)
+			},
+			mismatch: "Repr-Digest",
+		},
+		{
+			name: "trailer",
+			respond: func(w http.ResponseWriter) {
+				w.Header().Set("Trailer", "Content-Digest")
+				_, _ = io.WriteString(w, body)
+				w.Header().Set("Content-Digest", sha256Digest(body))
+			},
+		},
+		{
+			name: "trailer mismatch",
+			respond: func(w http.ResponseWriter) {
+				w.Header().Set("Trailer", "Content-Digest")
+				_, _ = io.WriteString(w, body)
+				w.Header().Set("Content-Digest", sha256Digest("other"))
+			},
+			mismatch: "Content-Digest",
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				tc.respond(w)
+			})
+			defer server.Assert(t)
+
+			client := httpclient.NewFrom(server.Client())
+			client.Use(httpclient.ContentDigest(httpclient.ContentDigestOptions{}))
+
+			resp, err := client.Get(context.Background(), server.URL)
+			requireEqual(t, nil, err, "call error")
+			defer resp.Body.Close()
+
+			data, errRead := io.ReadAll(resp.Body)
+
+			if tc.mismatch == "" {
+				requireEqual(t, nil, errRead, "read error")
+				assertEqual(t, body, string(data), "response body")
+				return
+			}
+
+			mismatch := &httpclient.DigestMismatchError{}
+			requireEqual(t, true, errors.As(errRead, &mismatch), "unexpected error: %v", errRead)
+			assertEqual(t, tc.mismatch, mismatch.Field, "mismatch field")
+			assertEqual(t, httpclient.DigestSHA256, mismatch.Algorithm, "mismatch algorithm")
+		})
+	}
+}
+
+func TestVerifyDigest(t *testing.T) {
+	t.Parallel()
+
+	err := httpclient.VerifyDigest("Content-Digest", []string{sha256Digest("hello")}, strings.NewReader("hello"))
+	assertEqual(t, nil, err, "valid digest")
+
+	err = httpclient.VerifyDigest("Content-Digest", []string{sha256Digest("hello")}, strings.NewReader("world"))
+	mismatch := &httpclient.DigestMismatchError{}
+	assertEqual(t, true, errors.As(err, &mismatch), "unexpected error: %v", err)
+
+	err = httpclient.VerifyDigest("Content-Digest", []string{"md5=:AAAA:"}, strings.NewReader("hello"))
+	assertEqual(t, true, errors.Is(err, httpclient.ErrUnsupportedDigest), "unexpected error: %v", err)
+}
@@ -0,0 +1,325 @@
+package httpclient
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DigestAuth creates a wrapper which authenticates requests using HTTP Digest authentication (RFC 7616).
+//
+// The wrapper answers the 401 Unauthorized challenges of the server: it computes the response
+// using MD5, MD5-sess, SHA-256 or SHA-256-sess algorithms with qop=auth and replays the request.
+// The last challenge of every origin is reused for next requests to the same origin
+// with an incremented nonce count, so they don't require an extra round trip.
+// Requests to other origins are not sent credentials until they are challenged.
+// Requests with bodies which can't be rewound fail with ErrBodyNotRewindable
+// if they have to be replayed.
+func DigestAuth(username, password string) Wrapper {
+	auth := &digestAuth{
+		username:   username,
+		password:   password,
+		challenges: map[string]*digestChallenge{},
+		counts:     map[digestNonce]uint32{},
+	}
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return auth.do(next, req)
+		})
+	}
+}
+
+type digestAuth struct {
+	username string
+	password string
+
+	mu sync.Mutex
+	// challenges are the last challenges by origin.
+	challenges map[string]*digestChallenge
+	// counts tracks the nonce count of every server nonce.
+	counts map[digestNonce]uint32
+}
+
+// digestNonce identifies a nonce in its protection space.
+type digestNonce struct {
+	origin string
+	realm  string
+	nonce  string
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       bool
+	userhash  bool
+	stale     bool
+}
+
+func (auth *digestAuth) do(next Doer, req *http.Request) (*http.Response, error) {
+	authReq := req.Clone(req.Context())
+	origin := digestOrigin(req)
+
+	challenge := auth.current(origin)
+	if challenge != nil {
+		authReq.Header.Set("Authorization", auth.authorization(req, origin, challenge))
+	}
+
+	resp, err := next.Do(authReq)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	newChallenge := parseDigestChallenge(resp.Header.Values("Www-Authenticate"))
+	if newChallenge == nil {
+		return resp, nil
+	}
+
+	// the server rejected the credentials for a valid nonce
+	if challenge != nil && challenge.nonce == newChallenge.nonce && !newChallenge.stale {
+		return resp, nil
+	}
+
+	auth.update(origin, newChallenge)
+
+	body, errRewind := rewindBody(req)
+	if errRewind != nil {
+		drainAndClose(resp.Body)
+		return nil, fmt.Errorf("digest auth: %w", errRewind)
+	}
+	drainAndClose(resp.Body)
+
+	replayReq := req.Clone(req.Context())
+	replayReq.Body = body
+	replayReq.Header.Set("Authorization", auth.authorization(req, origin, newChallenge))
+
+	return next.Do(replayReq)
+}
+
+// digestOrigin returns the scheme and host of the request.
+func digestOrigin(req *http.Request) string {
+	return strings.ToLower(req.URL.Scheme + "://" + req.URL.Host)
+}
+
+func (auth *digestAuth) current(origin string) *digestChallenge {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+
+	return auth.challenges[origin]
+}
+
+func (auth *digestAuth) update(origin string, challenge *digestChallenge) {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+
+	auth.challenges[origin] = challenge
+	// counts of the previous nonces of the origin are not needed anymore
+	for key := range auth.counts {
+		if key.origin == origin && (key.realm != challenge.realm || key.nonce != challenge.nonce) {
+			delete(auth.counts, key)
+		}
+	}
+}
+
+func (auth *digestAuth) nextCount(key digestNonce) uint32 {
+	auth.mu.Lock()
+	defer auth.mu.Unlock()
+
+	auth.counts[key]++
+	return auth.counts[key]
+}
+
+func (auth *digestAuth) authorization(req *http.Request, origin string, challenge *digestChallenge) string {
+	newHash := digestHash(challenge.algorithm)
+	h := func(parts ...string) string {
+		hasher := newHash()
+		hasher.Write([]byte(strings.Join(parts, ":")))
+		return hex.EncodeToString(hasher.Sum(nil))
+	}
+
+	uri := req.URL.RequestURI()
+	cnonce := newCNonce()
+	nc := fmt.Sprintf("%08x", auth.nextCount(digestNonce{origin: origin, realm: challenge.realm, nonce: challenge.nonce}))
+
+	ha1 := h(auth.username, challenge.realm, auth.password)
+	if strings.HasSuffix(strings.ToLower(challenge.algorithm), "-sess") {
+		ha1 = h(ha1, challenge.nonce, cnonce)
+	}
+	ha2 := h(req.Method, uri)
+
+	var response string
+	if challenge.qop {
+		response = h(ha1, challenge.nonce, nc, cnonce, "auth", ha2)
+	} else {
+		response = h(ha1, challenge.nonce, ha2)
+	}
+
+	username := auth.username
+	if challenge.userhash {
+		username = h(auth.username, challenge.realm)
+	}
+
+	params := []string{
+		fmt.Sprintf("username=%q", username),
+		fmt.Sprintf("realm=%q", challenge.realm),
+		fmt.Sprintf("uri=%q", uri),
+		"algorithm=" + challenge.algorithm,
+		fmt.Sprintf("nonce=%q", challenge.nonce),
+	}
+	if challenge.qop {
+		params = append(params, "nc="+nc, fmt.Sprintf("cnonce=%q", cnonce), "qop=auth")
+	}
+	params = append(params, fmt.Sprintf("response=%q", response))
+	if challenge.opaque != "" {
+		params = append(params, fmt.Sprintf("opaque=%q", challenge.opaque))
+	}
+	if challenge.userhash {
+		params = append(params, "userhash=true")
+	}
+
+	return "Digest " + strings.Join(params, ", ")
+}
+
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.ToUpper(algorithm) {
+	case "SHA-256", "SHA-256-SESS":
+		return sha256.New
+	default:
+		return md5.New
+	}
+}
+
+// digestAlgorithms are the supported algorithms, from the most preferred one.
+var digestAlgorithms = []string{"SHA-256", "SHA-256-sess", "MD5", "MD5-sess"}
+
+// parseDigestChallenge returns the most preferred supported Digest challenge.
+func parseDigestChallenge(headers []string) *digestChallenge {
+	var best *digestChallenge
+	bestRank := len(digestAlgorithms)
+
+	for _, header := range headers {
+		for _, challenge := range parseChallenges(header) {
+			if !strings.EqualFold(challenge.scheme, "Digest") {
+				continue
+			}
+
+			params := challenge.params
+			algorithm := params["algorithm"]
+			if algorithm == "" {
+				algorithm = "MD5"
+			}
+
+			rank := -1
+			for i, supported := range digestAlgorithms {
+				if strings.EqualFold(supported, algorithm) {
+					rank = i
+				}
+			}
+
+			qop, hasQOP := params["qop"]
+			supportsAuth := false
+			for _, option := range strings.Split(qop, ",") {
+				supportsAuth = supportsAuth || strings.EqualFold(strings.TrimSpace(option), "auth")
+			}
+
+			if rank < 0 || params["nonce"] == "" || (hasQOP && !supportsAuth) || rank >= bestRank {
+				continue
+			}
+
+			bestRank = rank
+			best = &digestChallenge{
+				realm:     params["realm"],
+				nonce:     params["nonce"],
+				opaque:    params["opaque"],
+				algorithm: digestAlgorithms[rank],
+				qop:       hasQOP,
+				userhash:  strings.EqualFold(params["userhash"], "true"),
+				stale:     strings.EqualFold(params["stale"], "true"),
+			}
+		}
+	}
+
+	return best
+}
+
+type authChallenge struct {
+	scheme string
+	params map[string]string
+}
+
+// parseChallenges parses the value of the WWW-Authenticate header,
+// which may contain several comma-separated challenges.
+func parseChallenges(header string) []authChallenge {
+	var challenges []authChallenge
+
+	rest := header
+	for {
+		rest = strings.TrimLeft(rest, " \t,")
+		if rest == "" {
+			return challenges
+		}
+
+		var token string
+		token, rest = readToken(rest)
+		if token == "" {
+			// skip an unexpected character
+			rest = rest[1:]
+			continue
+		}
+
+		trimmed := strings.TrimLeft(rest, " \t")
+		if !strings.HasPrefix(trimmed, "=") || len(challenges) == 0 {
+			challenges = append(challenges, authChallenge{scheme: token, params: map[string]string{}})
+			continue
+		}
+
+		var value string
+		value, rest = readParamValue(strings.TrimLeft(trimmed[1:], " \t"))
+		challenges[len(challenges)-1].params[strings.ToLower(token)] = value
+	}
+}
+
+func readToken(s string) (token, rest string) {
+	end := strings.IndexAny(s, " \t,=\"")
+	if end < 0 {
+		return s, ""
+	}
+	return s[:end], s[end:]
+}
+
+func readParamValue(s string) (value, rest string) {
+	if !strings.HasPrefix(s, `"`) {
+		return readToken(s)
+	}
+
+	b := strings.Builder{}
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String(), ""
+}
+
+func newCNonce() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,299 @@
+package httpclient_test
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ninedraft/httpclient"
+)
+
+func TestDigestAuth(t *testing.T) {
+	t.Parallel()
+
+	for _, algorithm := range []string{"MD5", "MD5-sess", "SHA-256", "SHA-256-sess"} {
+		algorithm := algorithm
+		t.Run(algorithm, func(t *testing.T) {
+			t.Parallel()
+
+			digest := &digestServer{t: t, algorithm: algorithm, nonce: "nonce-1"}
+			server := testServer(t, digest.handle)
+			defer server.Assert(t)
+
+			client := httpclient.NewFrom(server.Client())
+			client.Use(httpclient.DigestAuth("user", "secret"))
+
+			for i := 0; i < 2; i++ {
+				resp, err := client.Get(context.Background(), server.URL+"/dir/index.html?q=1")
+				requireEqual(t, nil, err, "call error")
+				resp.Body.Close()
+				assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+			}
+
+			// the challenge is reused by the second request with an incremented nonce count
+			assertEqualSlices(t, []string{"", "00000001", "00000002"}, digest.counts(), "nonce counts")
+		})
+	}
+}
+
+func TestDigestAuth_OtherOrigin(t *testing.T) {
+	t.Parallel()
+
+	digest := &digestServer{t: t, algorithm: "SHA-256", nonce: "nonce-1"}
+	challenging := testServer(t, digest.handle)
+	defer challenging.Assert(t)
+
+	other := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, "", r.Header.Get("Authorization"), "credentials are sent to another origin")
+		w.WriteHeader(http.StatusOK)
+	})
+	defer other.Assert(t)
+
+	client := httpclient.NewFrom(challenging.Client())
+	client.Use(httpclient.DigestAuth("user", "secret"))
+
+	for _, addr := range []string{challenging.URL, other.URL, challenging.URL} {
+		resp, err := client.Get(context.Background(), addr)
+		requireEqual(t, nil, err, "call error")
+		resp.Body.Close()
+		assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+	}
+
+	// the nonce count of the challenging origin is not affected by the other one
+	assertEqualSlices(t, []string{"", "00000001", "00000002"}, digest.counts(), "nonce counts")
+}
+
+func TestDigestAuth_PreferSHA256(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		params := parseDigestParams(r.Header.Get("Authorization"))
+		if params == nil {
+			w.Header().Add("WWW-Authenticate", `Digest realm="test", qop="auth", algorithm=MD5, nonce="n"`)
+			w.Header().Add("WWW-Authenticate", `Basic realm="test", Digest realm="test", qop="auth", algorithm=SHA-256, nonce="n"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		assertEqual(t, "SHA-256", params["algorithm"], "algorithm")
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.DigestAuth("user", "secret"))
+
+	resp, err := client.Get(context.Background(), server.URL)
+	requireEqual(t, nil, err, "call error")
+	resp.Body.Close()
+	assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+}
+
+func TestDigestAuth_StaleNonce(t *testing.T) {
+	t.Parallel()
+
+	digest := &digestServer{t: t, algorithm: "SHA-256", nonce: "nonce-1"}
+	server := testServer(t, digest.handle)
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.DigestAuth("user", "secret"))
+
+	get := func() {
+		resp, err := client.Get(context.Background(), server.URL)
+		requireEqual(t, nil, err, "call error")
+		resp.Body.Close()
+		assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+	}
+
+	get()
+	digest.rotate("nonce-2")
+	get()
+
+	assertEqualSlices(t, []string{"", "00000001", "00000002", "00000001"}, digest.counts(), "nonce counts")
+}
+
+func TestDigestAuth_WrongPassword(t *testing.T) {
+	t.Parallel()
+
+	digest := &digestServer{t: t, algorithm: "MD5", nonce: "nonce-1"}
+	server := testServer(t, digest.handle)
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.DigestAuth("user", "wrong"))
+
+	resp, err := client.Get(context.Background(), server.URL)
+	requireEqual(t, nil, err, "call error")
+	resp.Body.Close()
+
+	assertEqual(t, http.StatusUnauthorized, resp.StatusCode, "status code")
+	assertEqual(t, 2, len(digest.counts()), "requests")
+}
+
+func TestDigestAuth_Body(t *testing.T) {
+	t.Parallel()
+
+	digest := &digestServer{t: t, algorithm: "SHA-256", nonce: "nonce-1"}
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, `{"name":"gopher"}`, strings.TrimSpace(readString(t, r.Body)), "request body")
+		digest.handle(w, r)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.DigestAuth("user", "secret"))
+
+	resp, err := client.PostJSON(context.Background(), server.URL, map[string]string{"name": "gopher"})
+	requireEqual(t, nil, err, "call error")
+	resp.Body.Close()
+
+	assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+}
+
+func TestDigestAuth_Form(t *testing.T) {
+	t.Parallel()
+
+	digest := &digestServer{t: t, algorithm: "MD5-sess", nonce: "nonce-1"}
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, "name=gopher", readString(t, r.Body), "request body")
+		digest.handle(w, r)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.DigestAuth("user", "secret"))
+
+	resp, err := client.PostForm(context.Background(), server.URL, url.Values{"name": {"gopher"}})
+	requireEqual(t, nil, err, "call error")
+	resp.Body.Close()
+
+	assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+}
+
+func TestDigestAuth_NotRewindable(t *testing.T) {
+	t.Parallel()
+
+	digest := &digestServer{t: t, algorithm: "SHA-256", nonce: "nonce-1"}
+	server := testServer(t, digest.handle)
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.DigestAuth("user", "secret"))
+
+	body := io.MultiReader(strings.NewReader("hello"))
+	_, err := client.Post(context.Background(), server.URL, "text/plain", body)
+
+	assertEqual(t, true, errors.Is(err, httpclient.ErrBodyNotRewindable), "unexpected error: %v", err)
+}
+
+// digestServer validates Digest credentials of user:secret.
+type digestServer struct {
+	t         *testing.T
+	algorithm string
+
+	mu    sync.Mutex
+	nonce string
+	seen  []string
+}
+
+func (digest *digestServer) rotate(nonce string) {
+	digest.mu.Lock()
+	defer digest.mu.Unlock()
+
+	digest.nonce = nonce
+}
+
+// counts returns the nonce counts of all received requests, empty for unauthorized ones.
+func (digest *digestServer) counts() []string {
+	digest.mu.Lock()
+	defer digest.mu.Unlock()
+
+	return append([]string(nil), digest.seen...)
+}
+
+func (digest *digestServer) handle(w http.ResponseWriter, r *http.Request) {
+	digest.mu.Lock()
+	nonce := digest.nonce
+	digest.mu.Unlock()
+
+	params := parseDigestParams(r.Header.Get("Authorization"))
+
+	digest.mu.Lock()
+	digest.seen = append(digest.seen, params["nc"])
+	digest.mu.Unlock()
+
+	challenge := func(stale bool) {
+		value := `Digest realm="test", qop="auth", algorithm=` + digest.algorithm +
+			`, nonce="` + nonce + `", opaque="opaque-value"`
+		if stale {
+			value += ", stale=true"
+		}
+		w.Header().Set("WWW-Authenticate", value)
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+
+	switch {
+	case params == nil:
+		challenge(false)
+		return
+	case params["nonce"] != nonce:
+		challenge(true)
+		return
+	}
+
+	assertEqual(digest.t, "user", params["username"], "username")
+	assertEqual(digest.t, digest.algorithm, params["algorithm"], "algorithm")
+	assertEqual(digest.t, "opaque-value", params["opaque"], "opaque")
+	assertEqual(digest.t, r.URL.RequestURI(), params["uri"], "uri")
+
+	newHash := md5.New
+	if strings.HasPrefix(digest.algorithm, "SHA-256") {
+		newHash = sha256.New
+	}
+
+	ha1 := hexHash(newHash, "user", "test", "secret")
+	if strings.HasSuffix(digest.algorithm, "-sess") {
+		ha1 = hexHash(newHash, ha1, nonce, params["cnonce"])
+	}
+	ha2 := hexHash(newHash, r.Method, params["uri"])
+	expected := hexHash(newHash, ha1, nonce, params["nc"], params["cnonce"], "auth", ha2)
+
+	if params["response"] != expected {
+		challenge(false)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func hexHash(newHash func() hash.Hash, parts ...string) string {
+	hasher := newHash()
+	hasher.Write([]byte(strings.Join(parts, ":")))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// parseDigestParams parses the Authorization header produced by DigestAuth.
+// It returns nil if the header is not a Digest one.
+func parseDigestParams(header string) map[string]string {
+	rest, ok := strings.CutPrefix(header, "Digest ")
+	if !ok {
+		return nil
+	}
+
+	params := map[string]string{}
+	for _, item := range strings.Split(rest, ", ") {
+		key, value, _ := strings.Cut(item, "=")
+		params[key] = strings.Trim(value, `"`)
+	}
+	return params
+}
@@ -4,56 +4,37 @@ import (
 	"context"
 	"net/http"
 	"net/url"
-	"strings"
-
-	"golang.org/x/exp/maps"
 )
 
 const encodingURL = "application/x-www-form-urlencoded"
 
 // GetForm makes a GET request to the given address with the given form data.
 // The data is encoded as URL query parameters.
-func (client *Client) GetForm(ctx context.Context, addr string, data url.Values) (*http.Response, error) {
-	u, errParse := url.Parse(addr)
-	if errParse != nil {
-		return nil, errParse
-	}
-
-	q := u.Query()
-	maps.Copy(q, data)
-	u.RawQuery = q.Encode()
-
-	return client.Get(ctx, u.String())
+// Form values replace the address query parameters with the same keys and are replaced by the WithQuery ones.
+func (client *Client) GetForm(ctx context.Context, addr string, data url.Values, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(http.MethodGet, addr).Form(data).Apply(opts...).Do(ctx)
 }
 
 // PostForm makes a POST request to the given address with the given form data.
 // The data is encoded as "application/x-www-form-urlencoded".
-func (client *Client) PostForm(ctx context.Context, addr string, data url.Values) (*http.Response, error) {
-	body := strings.NewReader(data.Encode())
-
-	return client.Post(ctx, addr, encodingURL, body)
+func (client *Client) PostForm(ctx context.Context, addr string, data url.Values, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(http.MethodPost, addr).Form(data).Apply(opts...).Do(ctx)
 }
 
 // PutForm makes a PUT request to the given address with the given form data.
 // The data is encoded as "application/x-www-form-urlencoded".
-func (client *Client) PutForm(ctx context.Context, addr string, data url.Values) (*http.Response, error) {
-	body := strings.NewReader(data.Encode())
-
-	return client.Put(ctx, addr, encodingURL, body)
+func (client *Client) PutForm(ctx context.Context, addr string, data url.Values, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(http.MethodPut, addr).Form(data).Apply(opts...).Do(ctx)
 }
 
 // PatchForm makes a PATCH request to the given address with the given form data.
 // The data is encoded as "application/x-www-form-urlencoded".
-func (client *Client) PatchForm(ctx context.Context, addr string, data url.Values) (*http.Response, error) {
-	body := strings.NewReader(data.Encode())
-
-	return client.Patch(ctx, addr, encodingURL, body)
+func (client *Client) PatchForm(ctx context.Context, addr string, data url.Values, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(http.MethodPatch, addr).Form(data).Apply(opts...).Do(ctx)
 }
 
 // QueryForm makes a QUERY request to the given address with the given form data.
 // The data is encoded as "application/x-www-form-urlencoded".
-func (client *Client) QueryForm(ctx context.Context, addr string, data url.Values) (*http.Response, error) {
-	body := strings.NewReader(data.Encode())
-
-	return client.Query(ctx, addr, encodingURL, body)
+func (client *Client) QueryForm(ctx context.Context, addr string, data url.Values, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(methodQuery, addr).Form(data).Apply(opts...).Do(ctx)
 }
@@ -18,7 +18,7 @@ var methodsForms = map[string]methodForm{
 	http.MethodGet:   (*httpclient.Client).GetForm,
 }
 
-type methodForm = func(client *httpclient.Client, ctx context.Context, addr string, data url.Values) (*http.Response, error)
+type methodForm = func(client *httpclient.Client, ctx context.Context, addr string, data url.Values, opts ...httpclient.RequestOption) (*http.Response, error)
 
 func TestClient_Form(t *testing.T) {
 	const formKey = "foo"
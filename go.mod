@@ -1,5 +1,5 @@
 module github.com/ninedraft/httpclient
 
-go 1.20
+go 1.21
 
 require golang.org/x/exp v0.0.0-20230213192124-5e25df0256eb
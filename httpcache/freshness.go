@@ -0,0 +1,153 @@
+package httpcache
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// directives are parsed Cache-Control directives with lowercase names.
+// Directives without arguments have empty values.
+type directives map[string]string
+
+func parseDirectives(header http.Header) directives {
+	parsed := directives{}
+	for _, value := range header.Values("Cache-Control") {
+		for value != "" {
+			var directive string
+			directive, value = nextDirective(value)
+
+			name, arg, _ := strings.Cut(directive, "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if _, seen := parsed[name]; !seen {
+				parsed[name] = strings.Trim(strings.TrimSpace(arg), `"`)
+			}
+		}
+	}
+	return parsed
+}
+
+// nextDirective splits the comma-separated list, respecting quoted strings.
+func nextDirective(list string) (directive, rest string) {
+	quoted := false
+	for i := 0; i < len(list); i++ {
+		switch list[i] {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			i++
+		case ',':
+			if !quoted {
+				return list[:i], list[i+1:]
+			}
+		}
+	}
+	return list, ""
+}
+
+func (d directives) has(name string) bool {
+	_, ok := d[name]
+	return ok
+}
+
+// seconds returns the delta-seconds argument of the directive.
+func (d directives) seconds(name string) (time.Duration, bool) {
+	arg, ok := d[name]
+	if !ok {
+		return 0, false
+	}
+
+	seconds, errParse := strconv.ParseInt(arg, 10, 64)
+	if errParse != nil || seconds < 0 {
+		return 0, false
+	}
+	if seconds > maxDeltaSeconds {
+		seconds = maxDeltaSeconds
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// maxDeltaSeconds is the greatest delta-seconds value, as recommended by RFC 9111.
+const maxDeltaSeconds = 1 << 31
+
+// heuristicStatus are the status codes which are heuristically cacheable by default.
+var heuristicStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// heuristicFraction of the time since the last modification is used as the heuristic freshness lifetime.
+const heuristicFraction = 10
+
+// freshnessLifetime returns the freshness lifetime of the response, RFC 9111 section 4.2.1.
+func freshnessLifetime(status int, header http.Header, cc directives) time.Duration {
+	if maxAge, ok := cc.seconds("max-age"); ok {
+		return maxAge
+	}
+
+	date := responseDate(header)
+	if expires := header.Get("Expires"); expires != "" {
+		expiresAt, errParse := http.ParseTime(expires)
+		if errParse != nil || date.IsZero() || !expiresAt.After(date) {
+			return 0
+		}
+		return expiresAt.Sub(date)
+	}
+
+	if !heuristicStatus[status] && !cc.has("public") {
+		return 0
+	}
+	lastModified, errParse := http.ParseTime(header.Get("Last-Modified"))
+	if errParse != nil || date.IsZero() || !date.After(lastModified) {
+		return 0
+	}
+	return date.Sub(lastModified) / heuristicFraction
+}
+
+// hasExplicitExpiration reports whether the response defines its freshness lifetime.
+func hasExplicitExpiration(header http.Header, cc directives) bool {
+	return cc.has("max-age") || header.Get("Expires") != ""
+}
+
+func responseDate(header http.Header) time.Time {
+	date, errParse := http.ParseTime(header.Get("Date"))
+	if errParse != nil {
+		return time.Time{}
+	}
+	return date
+}
+
+// currentAge returns the age of the stored response, RFC 9111 section 4.2.3.
+func (e *entry) currentAge(now time.Time) time.Duration {
+	apparentAge := time.Duration(0)
+	if date := responseDate(e.Header); !date.IsZero() && e.ResponseTime.After(date) {
+		apparentAge = e.ResponseTime.Sub(date)
+	}
+
+	ageValue := time.Duration(0)
+	if seconds, errParse := strconv.ParseInt(strings.TrimSpace(e.Header.Get("Age")), 10, 64); errParse == nil && seconds > 0 {
+		ageValue = time.Duration(seconds) * time.Second
+	}
+
+	responseDelay := e.ResponseTime.Sub(e.RequestTime)
+	correctedInitialAge := ageValue + responseDelay
+	if apparentAge > correctedInitialAge {
+		correctedInitialAge = apparentAge
+	}
+
+	residentTime := now.Sub(e.ResponseTime)
+	return correctedInitialAge + residentTime
+}
@@ -0,0 +1,603 @@
+// Package httpcache implements a private HTTP cache, as defined by RFC 9111.
+//
+// The cache stores responses to GET requests and serves them while they are fresh.
+// Stale responses are revalidated with conditional requests, served while revalidating
+// in background within stale-while-revalidate, or served on errors within stale-if-error, RFC 5861.
+// Successful unsafe requests invalidate the stored responses of their URLs.
+//
+//	cache := &httpcache.Cache{Storage: httpcache.NewMemoryStorage(64 << 20)}
+//	client.Use(cache.Wrapper())
+//
+// Responses are annotated with the Cache-Status header, RFC 9211.
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ninedraft/httpclient"
+)
+
+// DefaultMaxEntrySize is the default limit of stored response bodies.
+const DefaultMaxEntrySize = 10 << 20
+
+// Cache is a private HTTP cache. It is safe for concurrent use.
+// Add its wrapper before Retry, so that retries are not cached separately.
+type Cache struct {
+	Storage Storage
+
+	// Name identifies the cache in the Cache-Status header. Default is "httpcache".
+	Name string
+
+	// MaxEntrySize limits the size of stored response bodies.
+	// Default is DefaultMaxEntrySize.
+	MaxEntrySize int64
+
+	// Now returns the current time. Default is time.Now.
+	Now func() time.Time
+
+	mu sync.Mutex
+	// revalidating are the keys of background revalidations in progress.
+	revalidating map[string]bool
+}
+
+// Wrapper returns a wrapper which serves responses from the cache.
+func (cache *Cache) Wrapper() httpclient.Wrapper {
+	return func(next httpclient.Doer) httpclient.Doer {
+		return httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return cache.do(next, req)
+		})
+	}
+}
+
+// entry is a stored response.
+// Responses with the Vary header are stored as variants,
+// and the entry of their URL only lists the variant keys.
+type entry struct {
+	Vary     []string
+	Variants []string
+
+	RequestTime  time.Time
+	ResponseTime time.Time
+	StatusCode   int
+	Header       http.Header
+	Body         []byte
+}
+
+func (e *entry) isVariants() bool {
+	return e.StatusCode == 0
+}
+
+func (cache *Cache) do(next httpclient.Doer, req *http.Request) (*http.Response, error) {
+	switch {
+	case req.Method == http.MethodGet && req.Header.Get("Range") == "" && !isConditional(req.Header):
+	case isUnsafe(req.Method):
+		resp, err := next.Do(req)
+		if err == nil && resp.StatusCode < 400 {
+			cache.invalidate(req.URL, resp.Header)
+		}
+		return resp, err
+	default:
+		return next.Do(req)
+	}
+
+	reqCC := requestDirectives(req.Header)
+	key := cacheKey(req.URL)
+
+	stored, miss := cache.lookup(key, req.Header)
+	if stored == nil {
+		if reqCC.has("only-if-cached") {
+			return gatewayTimeout(req), nil
+		}
+		return cache.forward(next, req, key, reqCC, miss)
+	}
+
+	now := cache.now()
+	respCC := parseDirectives(stored.Header)
+	age := stored.currentAge(now)
+	lifetime := freshnessLifetime(stored.StatusCode, stored.Header, respCC)
+
+	if usable(reqCC, respCC, age, lifetime) {
+		return cache.serve(req, stored, age, fmt.Sprintf("hit; ttl=%d", seconds(lifetime-age))), nil
+	}
+
+	if swr, ok := respCC.seconds("stale-while-revalidate"); ok && age-lifetime <= swr &&
+		!reqCC.has("no-cache") && !respCC.has("no-cache") && !respCC.has("must-revalidate") {
+		cache.revalidateInBackground(next, req, key, stored)
+		return cache.serve(req, stored, age, fmt.Sprintf("hit; ttl=%d; detail=stale-while-revalidate", seconds(lifetime-age))), nil
+	}
+
+	if reqCC.has("only-if-cached") {
+		return gatewayTimeout(req), nil
+	}
+
+	fwd := "stale"
+	if reqCC.has("no-cache") || (respCC.has("no-cache") && age < lifetime) {
+		fwd = "request"
+	}
+	return cache.revalidate(next, req, key, stored, reqCC, fwd)
+}
+
+// usable reports whether the stored response can be served without validation.
+func usable(reqCC, respCC directives, age, lifetime time.Duration) bool {
+	if reqCC.has("no-cache") || respCC.has("no-cache") {
+		return false
+	}
+	if maxAge, ok := reqCC.seconds("max-age"); ok && age > maxAge {
+		return false
+	}
+	if minFresh, ok := reqCC.seconds("min-fresh"); ok && lifetime-age < minFresh {
+		return false
+	}
+	if age < lifetime {
+		return true
+	}
+
+	if respCC.has("must-revalidate") || !reqCC.has("max-stale") {
+		return false
+	}
+	if maxStale, ok := reqCC.seconds("max-stale"); ok {
+		return age-lifetime <= maxStale
+	}
+	return reqCC["max-stale"] == ""
+}
+
+// revalidate sends a conditional request for the stored response.
+func (cache *Cache) revalidate(next httpclient.Doer, req *http.Request, key string, stored *entry, reqCC directives, fwd string) (*http.Response, error) {
+	etag, lastModified := stored.Header.Get("ETag"), stored.Header.Get("Last-Modified")
+
+	conditional := req.Clone(req.Context())
+	if etag != "" {
+		conditional.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		conditional.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	requestTime := cache.now()
+	resp, err := next.Do(conditional)
+
+	if err != nil || resp.StatusCode >= 500 {
+		now := cache.now()
+		respCC := parseDirectives(stored.Header)
+		age := stored.currentAge(now)
+		lifetime := freshnessLifetime(stored.StatusCode, stored.Header, respCC)
+		if !staleIfError(reqCC, respCC, age-lifetime) {
+			return resp, err
+		}
+
+		if err == nil {
+			drainAndClose(resp.Body)
+		}
+		return cache.serve(req, stored, age, fmt.Sprintf("hit; ttl=%d; detail=stale-if-error", seconds(lifetime-age))), nil
+	}
+
+	if resp.StatusCode != http.StatusNotModified || (etag == "" && lastModified == "") {
+		return cache.handle(req, resp, key, reqCC, requestTime, fwd)
+	}
+
+	drainAndClose(resp.Body)
+
+	updated := *stored
+	updated.RequestTime = requestTime
+	updated.ResponseTime = cache.now()
+	updated.Header = stored.Header.Clone()
+	for name, values := range storedHeader(resp.Header) {
+		switch name {
+		case "Content-Length", "Content-Encoding", "Content-Range":
+		default:
+			updated.Header[name] = values
+		}
+	}
+	cache.store(key, req.Header, &updated)
+
+	return cache.serve(req, &updated, updated.currentAge(updated.ResponseTime), fwdStatus(fwd, resp.StatusCode)), nil
+}
+
+func staleIfError(reqCC, respCC directives, staleness time.Duration) bool {
+	if respCC.has("must-revalidate") || respCC.has("no-cache") {
+		return false
+	}
+
+	allowed, ok := respCC.seconds("stale-if-error")
+	if requested, okRequested := reqCC.seconds("stale-if-error"); okRequested && requested > allowed {
+		allowed, ok = requested, true
+	}
+	return ok && staleness <= allowed
+}
+
+func (cache *Cache) revalidateInBackground(next httpclient.Doer, req *http.Request, key string, stored *entry) {
+	cache.mu.Lock()
+	if cache.revalidating == nil {
+		cache.revalidating = map[string]bool{}
+	}
+	if cache.revalidating[key] {
+		cache.mu.Unlock()
+		return
+	}
+	cache.revalidating[key] = true
+	cache.mu.Unlock()
+
+	background := req.Clone(context.WithoutCancel(req.Context()))
+
+	go func() {
+		defer func() {
+			cache.mu.Lock()
+			delete(cache.revalidating, key)
+			cache.mu.Unlock()
+		}()
+
+		resp, err := cache.revalidate(next, background, key, stored, directives{}, "stale")
+		if err == nil {
+			// the body is read to the end to store the response
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+	}()
+}
+
+// forward sends the request and stores the response if possible.
+func (cache *Cache) forward(next httpclient.Doer, req *http.Request, key string, reqCC directives, fwd string) (*http.Response, error) {
+	requestTime := cache.now()
+	resp, err := next.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	return cache.handle(req, resp, key, reqCC, requestTime, fwd)
+}
+
+// handle stores the response when its body is read to the end.
+func (cache *Cache) handle(req *http.Request, resp *http.Response, key string, reqCC directives, requestTime time.Time, fwd string) (*http.Response, error) {
+	stored := &entry{
+		RequestTime:  requestTime,
+		ResponseTime: cache.now(),
+		StatusCode:   resp.StatusCode,
+		Header:       storedHeader(resp.Header),
+		Vary:         varyNames(resp.Header),
+	}
+	resp.Header.Add("Cache-Status", cache.name()+"; "+fwdStatus(fwd, resp.StatusCode))
+
+	if !storable(reqCC, stored) || resp.Body == nil {
+		return resp, nil
+	}
+
+	reqHeader := req.Header.Clone()
+	resp.Body = &cachingBody{
+		body:  resp.Body,
+		limit: cache.maxEntrySize(),
+		onEOF: func(body []byte) {
+			stored.Body = body
+			cache.store(key, reqHeader, stored)
+		},
+	}
+	return resp, nil
+}
+
+// storable reports whether the response can be stored, RFC 9111 section 3.
+func storable(reqCC directives, stored *entry) bool {
+	respCC := parseDirectives(stored.Header)
+
+	switch {
+	case reqCC.has("no-store"), respCC.has("no-store"),
+		stored.StatusCode < 200, stored.StatusCode == http.StatusPartialContent, stored.StatusCode == http.StatusNotModified:
+		return false
+	}
+	for _, name := range stored.Vary {
+		if name == "*" {
+			return false
+		}
+	}
+
+	if !hasExplicitExpiration(stored.Header, respCC) && !heuristicStatus[stored.StatusCode] &&
+		!respCC.has("public") && !respCC.has("private") && !respCC.has("no-cache") {
+		return false
+	}
+
+	// responses which are always stale and cannot be revalidated are useless
+	return freshnessLifetime(stored.StatusCode, stored.Header, respCC) > 0 ||
+		stored.Header.Get("ETag") != "" || stored.Header.Get("Last-Modified") != "" ||
+		respCC.has("stale-while-revalidate") || respCC.has("stale-if-error")
+}
+
+func (cache *Cache) serve(req *http.Request, stored *entry, age time.Duration, status string) *http.Response {
+	header := stored.Header.Clone()
+	header.Set("Age", strconv.FormatInt(seconds(age), 10))
+	header.Set("Cache-Status", cache.name()+"; "+status)
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", stored.StatusCode, http.StatusText(stored.StatusCode)),
+		StatusCode:    stored.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(stored.Body)),
+		ContentLength: int64(len(stored.Body)),
+		Request:       req,
+	}
+}
+
+func gatewayTimeout(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", http.StatusGatewayTimeout, http.StatusText(http.StatusGatewayTimeout)),
+		StatusCode: http.StatusGatewayTimeout,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+// lookup returns the stored response selected by the request headers,
+// or the Cache-Status forward reason.
+func (cache *Cache) lookup(key string, header http.Header) (*entry, string) {
+	stored, ok := cache.load(key)
+	if !ok {
+		return nil, "uri-miss"
+	}
+	if !stored.isVariants() {
+		return stored, ""
+	}
+
+	variant, ok := cache.load(variantKey(key, stored.Vary, header))
+	if !ok {
+		return nil, "vary-miss"
+	}
+	return variant, ""
+}
+
+func (cache *Cache) load(key string) (*entry, bool) {
+	data, errGet := cache.Storage.Get(key)
+	if errGet != nil {
+		return nil, false
+	}
+
+	stored := &entry{}
+	if errDecode := gob.NewDecoder(bytes.NewReader(data)).Decode(stored); errDecode != nil {
+		return nil, false
+	}
+	return stored, true
+}
+
+// store saves the response. Storage errors are ignored, the response is just not cached.
+func (cache *Cache) store(key string, reqHeader http.Header, stored *entry) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	existing, hasExisting := cache.load(key)
+	if hasExisting && existing.isVariants() && !equalNames(existing.Vary, stored.Vary) {
+		cache.deleteVariants(existing)
+	}
+
+	if len(stored.Vary) == 0 {
+		cache.save(key, stored)
+		return
+	}
+
+	variants := &entry{Vary: stored.Vary}
+	if hasExisting && existing.isVariants() && equalNames(existing.Vary, stored.Vary) {
+		variants.Variants = existing.Variants
+	}
+
+	variant := variantKey(key, stored.Vary, reqHeader)
+	if !containsString(variants.Variants, variant) {
+		variants.Variants = append(variants.Variants, variant)
+	}
+
+	cache.save(variant, stored)
+	cache.save(key, variants)
+}
+
+func (cache *Cache) save(key string, stored *entry) {
+	buf := &bytes.Buffer{}
+	if errEncode := gob.NewEncoder(buf).Encode(stored); errEncode != nil {
+		return
+	}
+	_ = cache.Storage.Set(key, buf.Bytes())
+}
+
+// invalidate removes the stored responses of the request URL
+// and of the same-origin Location and Content-Location URLs, RFC 9111 section 4.4.
+func (cache *Cache) invalidate(target *url.URL, respHeader http.Header) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.remove(cacheKey(target))
+	for _, name := range []string{"Location", "Content-Location"} {
+		location, errParse := target.Parse(respHeader.Get(name))
+		if respHeader.Get(name) == "" || errParse != nil {
+			continue
+		}
+		if location.Scheme == target.Scheme && location.Host == target.Host {
+			cache.remove(cacheKey(location))
+		}
+	}
+}
+
+func (cache *Cache) remove(key string) {
+	if stored, ok := cache.load(key); ok && stored.isVariants() {
+		cache.deleteVariants(stored)
+	}
+	_ = cache.Storage.Delete(key)
+}
+
+func (cache *Cache) deleteVariants(variants *entry) {
+	for _, variant := range variants.Variants {
+		_ = cache.Storage.Delete(variant)
+	}
+}
+
+func (cache *Cache) name() string {
+	if cache.Name == "" {
+		return "httpcache"
+	}
+	return cache.Name
+}
+
+func (cache *Cache) maxEntrySize() int64 {
+	if cache.MaxEntrySize <= 0 {
+		return DefaultMaxEntrySize
+	}
+	return cache.MaxEntrySize
+}
+
+func (cache *Cache) now() time.Time {
+	if cache.Now == nil {
+		return time.Now()
+	}
+	return cache.Now()
+}
+
+func requestDirectives(header http.Header) directives {
+	cc := parseDirectives(header)
+	if len(header.Values("Cache-Control")) == 0 {
+		for _, pragma := range header.Values("Pragma") {
+			if strings.Contains(strings.ToLower(pragma), "no-cache") {
+				cc["no-cache"] = ""
+			}
+		}
+	}
+	return cc
+}
+
+func cacheKey(u *url.URL) string {
+	withoutFragment := *u
+	withoutFragment.Fragment = ""
+	withoutFragment.RawFragment = ""
+	return withoutFragment.String()
+}
+
+func variantKey(key string, vary []string, header http.Header) string {
+	variant := strings.Builder{}
+	variant.WriteString(key)
+	for _, name := range vary {
+		values := strings.Split(strings.Join(header.Values(name), ","), ",")
+		for i, value := range values {
+			values[i] = strings.TrimSpace(value)
+		}
+		variant.WriteString("\x00" + name + ":" + strings.Join(values, ","))
+	}
+	return variant.String()
+}
+
+func varyNames(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name != "" && !containsString(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hopByHop are the header fields which are not stored, RFC 9111 section 3.1.
+var hopByHop = []string{
+	"Connection", "Keep-Alive", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+	"Cache-Status",
+}
+
+func storedHeader(header http.Header) http.Header {
+	stored := header.Clone()
+	for _, connection := range header.Values("Connection") {
+		for _, name := range strings.Split(connection, ",") {
+			stored.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range hopByHop {
+		stored.Del(name)
+	}
+	return stored
+}
+
+func isConditional(header http.Header) bool {
+	for _, name := range []string{"If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since", "If-Range"} {
+		if header.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func isUnsafe(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, "QUERY":
+		return false
+	default:
+		return true
+	}
+}
+
+func fwdStatus(fwd string, status int) string {
+	return fmt.Sprintf("fwd=%s; fwd-status=%d", fwd, status)
+}
+
+func seconds(d time.Duration) int64 {
+	return int64(d / time.Second)
+}
+
+func equalNames(a, b []string) bool {
+	return strings.Join(a, ",") == strings.Join(b, ",")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, 64<<10))
+	_ = body.Close()
+}
+
+// cachingBody buffers the response body and passes it to onEOF when it is read to the end.
+// Bodies larger than the limit are not buffered.
+type cachingBody struct {
+	body     io.ReadCloser
+	buf      bytes.Buffer
+	limit    int64
+	overflow bool
+	done     bool
+	onEOF    func(body []byte)
+}
+
+func (body *cachingBody) Read(p []byte) (int, error) {
+	n, err := body.body.Read(p)
+
+	if !body.overflow {
+		body.buf.Write(p[:n])
+		if int64(body.buf.Len()) > body.limit {
+			body.overflow = true
+			body.buf = bytes.Buffer{}
+		}
+	}
+
+	if err == io.EOF && !body.overflow && !body.done {
+		body.done = true
+		body.onEOF(body.buf.Bytes())
+	}
+	return n, err
+}
+
+func (body *cachingBody) Close() error {
+	return body.body.Close()
+}
@@ -0,0 +1,545 @@
+package httpcache_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ninedraft/httpclient"
+	"github.com/ninedraft/httpclient/httpcache"
+)
+
+// clock is a manually advanced time source shared by the cache and the origin.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newClock() *clock {
+	return &clock{now: time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type origin struct {
+	*httptest.Server
+	calls atomic.Int32
+
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+// newOrigin starts a server which sets the Date header from the clock.
+func newOrigin(t *testing.T, clk *clock, handler func(w http.ResponseWriter, r *http.Request)) *origin {
+	t.Helper()
+
+	o := &origin{}
+	o.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		o.calls.Add(1)
+		o.mu.Lock()
+		o.requests = append(o.requests, r.Clone(context.Background()))
+		o.mu.Unlock()
+
+		w.Header().Set("Date", clk.Now().Format(http.TimeFormat))
+		handler(w, r)
+	}))
+	t.Cleanup(o.Close)
+	return o
+}
+
+func (o *origin) lastRequest() *http.Request {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.requests[len(o.requests)-1]
+}
+
+func newClient(o *origin, clk *clock) (*httpclient.Client, *httpcache.Cache) {
+	cache := &httpcache.Cache{Storage: httpcache.NewMemoryStorage(1 << 20), Now: clk.Now}
+	client := httpclient.NewFrom(o.Client())
+	client.Use(cache.Wrapper())
+	return client, cache
+}
+
+type result struct {
+	status      int
+	body        string
+	cacheStatus string
+	age         string
+}
+
+func get(t *testing.T, client *httpclient.Client, addr string, header ...string) result {
+	t.Helper()
+
+	opts := []httpclient.RequestOption{}
+	for i := 0; i+1 < len(header); i += 2 {
+		opts = append(opts, httpclient.WithHeader(header[i], header[i+1]))
+	}
+
+	resp, err := client.Get(context.Background(), addr, opts...)
+	if err != nil {
+		t.Fatalf("call error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, errRead := io.ReadAll(resp.Body)
+	if errRead != nil {
+		t.Fatalf("read body: %v", errRead)
+	}
+
+	return result{
+		status:      resp.StatusCode,
+		body:        string(body),
+		cacheStatus: resp.Header.Get("Cache-Status"),
+		age:         resp.Header.Get("Age"),
+	}
+}
+
+func assertEqual[E comparable](t *testing.T, expected, got E, msg string, args ...any) {
+	t.Helper()
+	if expected != got {
+		t.Errorf(msg+": want %v, got %v", append(args, expected, got)...)
+	}
+}
+
+func TestCache_MaxAge(t *testing.T) {
+	t.Parallel()
+
+	clk := newClock()
+	version := atomic.Int32{}
+	o := newOrigin(t, clk, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = io.WriteString(w, "config "+string(rune('0'+version.Add(1))))
+	})
+	client, _ := newClient(o, clk)
+
+	first := get(t, client, o.URL)
+	assertEqual(t, "config 1", first.body, "first body")
+	assertEqual(t, "httpcache; fwd=uri-miss; fwd-status=200", first.cacheStatus, "first cache status")
+
+	clk.Advance(30 * time.Second)
+	second := get(t, client, o.URL)
+	assertEqual(t, "config 1", second.body, "cached body")
+	assertEqual(t, "httpcache; hit; ttl=30", second.cacheStatus, "hit cache status")
+	assertEqual(t, "30", second.age, "age")
+	assertEqual(t, 1, o.calls.Load(), "origin calls")
+
+	clk.Advance(time.Minute)
+	third := get(t, client, o.URL)
+	assertEqual(t, "config 1", third.body, "revalidated body")
+	assertEqual(t, "httpcache; fwd=stale; fwd-status=304", third.cacheStatus, "revalidated cache status")
+	assertEqual(t, `"v1"`, o.lastRequest().Header.Get("If-None-Match"), "conditional request")
+	assertEqual(t, 2, o.calls.Load(), "origin calls")
+
+	fourth := get(t, client, o.URL)
+	assertEqual(t, "httpcache; hit; ttl=60", fourth.cacheStatus, "hit after revalidation")
+	assertEqual(t, 2, o.calls.Load(), "origin calls")
+}
+
+func TestCache_Expires(t *testing.T) {
+	t.Parallel()
+
+	clk := newClock()
+	o := newOrigin(t, clk, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Expires", clk.Now().Add(time.Hour).Format(http.TimeFormat))
+		_, _ = io.WriteString(w, "body")
+	})
+	client, _ := newClient(o, clk)
+
+	get(t, client, o.URL)
+	clk.Advance(59 * time.Minute)
+	assertEqual(t, "httpcache; hit; ttl=60", get(t, client, o.URL).cacheStatus, "fresh")
+
+	clk.Advance(2 * time.Minute)
+	assertEqual(t, "httpcache; fwd=stale; fwd-status=200", get(t, client, o.URL).cacheStatus, "expired")
+	assertEqual(t, 2, o.calls.Load(), "origin calls")
+}
+
+func TestCache_Heuristic(t *testing.T) {
+	t.Parallel()
+
+	clk := newClock()
+	o := newOrigin(t, clk, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Modified-Since") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", clk.Now().Add(-10*24*time.Hour).Format(http.TimeFormat))
+		_, _ = io.WriteString(w, "body")
+	})
+	client, _ := newClient(o, clk)
+
+	get(t, client, o.URL)
+	lastModified := clk.Now().Add(-10 * 24 * time.Hour).Format(http.TimeFormat)
+
+	// the heuristic freshness lifetime is a tenth of the time since the last modification
+	clk.Advance(23 * time.Hour)
+	assertEqual(t, "httpcache; hit; ttl=3600", get(t, client, o.URL).cacheStatus, "heuristically fresh")
+
+	clk.Advance(2 * time.Hour)
+	revalidated := get(t, client, o.URL)
+	assertEqual(t, "body", revalidated.body, "revalidated body")
+	assertEqual(t, "httpcache; fwd=stale; fwd-status=304", revalidated.cacheStatus, "stale")
+	assertEqual(t, lastModified, o.lastRequest().Header.Get("If-Modified-Since"), "conditional request")
+}
+
+func TestCache_NotStored(t *testing.T) {
+	t.Parallel()
+
+	tcs := []struct {
+		name    string
+		status  int
+		respond func(w http.ResponseWriter)
+	}{
+		{
+			name:   "no-store",
+			status: http.StatusOK,
+			respond: func(w http.ResponseWriter) {
+				w.Header().Set("Cache-Control", "max-age=60, no-store")
+			},
+		},
+		{
+			name:   "vary all",
+			status: http.StatusOK,
+			respond: func(w http.ResponseWriter) {
+				w.Header().Set("Cache-Control", "max-age=60")
+				w.Header().Set("Vary", "*")
+			},
+		},
+		{
+			name:    "no validators",
+			status:  http.StatusOK,
+			respond: func(w http.ResponseWriter) {},
+		},
+		{
+			name:   "uncacheable status",
+			status: http.StatusInternalServerError,
+			respond: func(w http.ResponseWriter) {
+				w.Header().Set("ETag", `"v1"`)
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			clk := newClock()
+			o := newOrigin(t, clk, func(w http.ResponseWriter, r *http.Request) {
+				tc.respond(w)
+				_, _ = io.WriteString(w, "body")
+			})
+			client, _ := newClient(o, clk)
+
+			get(t, client, o.URL)
+			second := get(t, client, o.URL)
+			assertEqual(t, tc.status, second.status, "status")
+			assertEqual(t, "httpcache; fwd=uri-miss; fwd-status="+strconv.Itoa(tc.status), second.cacheStatus, "cache status")
+			assertEqual(t, 2, o.calls.Load(), "origin calls")
+		})
+	}
+}
+
+func TestCache_NoCache(t *testing.T) {
+	t.Parallel()
+
+	clk := newClock()
+	o := newOrigin(t, clk, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60, no-cache")
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") != "" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = io.WriteString(w, "body")
+	})
+	client, _ := newClient(o, clk)
+
+	get(t, client, o.URL)
+	second := get(t, client, o.URL)
+	assertEqual(t, "body", second.body, "body")
+	assertEqual(t, "httpcache; fwd=request; fwd-status=304", second.cacheStatus, "fresh response is revalidated")
+	assertEqual(t, 2, o.calls.Load(), "origin calls")
+}
+
+func TestCache_RequestDirectives(t *testing.T) {
+	t.Parallel()
+
+	clk := newClock()
+	o := newOrigin(t, clk, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, "body")
+	})
+	client, _ := newClient(o, clk)
+
+	missing := get(t, client, o.URL+"/missing", "Cache-Control", "only-if-cached")
+	assertEqual(t, http.StatusGatewayTimeout, missing.status, "only-if-cached miss")
+	assertEqual(t, 0, o.calls.Load(), "origin calls")
+
+	get(t, client, o.URL)
+	clk.Advance(30 * time.Second)
+
+	assertEqual(t, "httpcache; fwd=request; fwd-status=200",
+		get(t, client, o.URL, "Cache-Control", "no-cache").cacheStatus, "request no-cache")
+	assertEqual(t, "httpcache; fwd=request; fwd-status=200",
+		get(t, client, o.URL, "Pragma", "no-cache").cacheStatus, "pragma no-cache")
+
+	clk.Advance(30 * time.Second)
+	assertEqual(t, "httpcache; fwd=stale; fwd-status=200",
+		get(t, client, o.URL, "Cache-Control", "max-age=10").cacheStatus, "request max-age")
+	assertEqual(t, "httpcache; fwd=stale; fwd-status=200",
+		get(t, client, o.URL, "Cache-Control", "min-fresh=90").cacheStatus, "request min-fresh")
+
+	clk.Advance(90 * time.Second)
+	assertEqual(t, "httpcache; hit; ttl=-30",
+		get(t, client, o.URL, "Cache-Control", "max-stale=60").cacheStatus, "request max-stale")
+	assertEqual(t, "httpcache; hit; ttl=-30",
+		get(t, client, o.URL, "Cache-Control", "only-if-cached, max-stale").cacheStatus, "only-if-cached with max-stale")
+	assertEqual(t, 5, o.calls.Load(), "origin calls")
+}
+
+func TestCache_Vary(t *testing.T) {
+	t.Parallel()
+
+	clk := newClock()
+	o := newOrigin(t, clk, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		_, _ = io.WriteString(w, "hello in "+r.Header.Get("Accept-Language"))
+	})
+	client, _ := newClient(o, clk)
+
+	assertEqual(t, "hello in en", get(t, client, o.URL, "Accept-Language", "en").body, "en")
+	de := get(t, client, o.URL, "Accept-Language", "de")
+	assertEqual(t, "hello in de", de.body, "de")
+	assertEqual(t, "httpcache; fwd=vary-miss; fwd-status=200", de.cacheStatus, "de cache status")
+
+	en := get(t, client, o.URL, "Accept-Language", "en")
+	assertEqual(t, "hello in en", en.body, "cached en")
+	assertEqual(t, "httpcache; hit; ttl=60", en.cacheStatus, "en cache status")
+	assertEqual(t, "hello in de", get(t, client, o.URL, "Accept-Language", "de").body, "cached de")
+	assertEqual(t, 2, o.calls.Load(), "origin calls")
+}
+
+func TestCache_StaleWhileRevalidate(t *testing.T) {
+	t.Parallel()
+
+	clk := newClock()
+	version := atomic.Int32{}
+	o := newOrigin(t, clk, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60, stale-while-revalidate=30")
+		_, _ = io.WriteString(w, "version "+string(rune('0'+version.Add(1))))
+	})
+	client, _ := newClient(o, clk)
+
+	get(t, client, o.URL)
+	clk.Advance(70 * time.Second)
+
+	stale := get(t, client, o.URL)
+	assertEqual(t, "version 1", stale.body, "stale body")
+	assertEqual(t, "httpcache; hit; ttl=-10; detail=stale-while-revalidate", stale.cacheStatus, "stale cache status")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		fresh := get(t, client, o.URL)
+		if fresh.body == "version 2" {
+			assertEqual(t, "httpcache; hit; ttl=60", fresh.cacheStatus, "revalidated cache status")
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("response is not revalidated in background")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assertEqual(t, 2, o.calls.Load(), "origin calls")
+
+	clk.Advance(100 * time.Second)
+	assertEqual(t, "httpcache; fwd=stale; fwd-status=200", get(t, client, o.URL).cacheStatus, "beyond stale-while-revalidate")
+}
+
+func TestCache_StaleIfError(t *testing.T) {
+	t.Parallel()
+
+	tcs := []struct {
+		name         string
+		cacheControl string
+		advance      time.Duration
+		stale        bool
+	}{
+		{name: "within window", cacheControl: "max-age=60, stale-if-error=60", advance: 90 * time.Second, stale: true},
+		{name: "beyond window", cacheControl: "max-age=60, stale-if-error=60", advance: 150 * time.Second},
+		{name: "must-revalidate", cacheControl: "max-age=60, stale-if-error=60, must-revalidate", advance: 90 * time.Second},
+		{name: "no directive", cacheControl: "max-age=60", advance: 90 * time.Second},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			clk := newClock()
+			o := newOrigin(t, clk, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Cache-Control", tc.cacheControl)
+				w.Header().Set("ETag", `"v1"`)
+				if r.Header.Get("If-None-Match") != "" {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				_, _ = io.WriteString(w, "body")
+			})
+			client, _ := newClient(o, clk)
+
+			get(t, client, o.URL)
+			clk.Advance(tc.advance)
+
+			got := get(t, client, o.URL)
+			if tc.stale {
+				assertEqual(t, http.StatusOK, got.status, "status")
+				assertEqual(t, "body", got.body, "stale body")
+				assertEqual(t, "httpcache; hit; ttl=-30; detail=stale-if-error", got.cacheStatus, "cache status")
+			} else {
+				assertEqual(t, http.StatusBadGateway, got.status, "status")
+			}
+		})
+	}
+}
+
+func TestCache_StaleIfErrorTransport(t *testing.T) {
+	t.Parallel()
+
+	clk := newClock()
+	errTransport := errors.New("connection refused")
+	fail := atomic.Bool{}
+
+	cache := &httpcache.Cache{Storage: httpcache.NewMemoryStorage(1 << 20), Now: clk.Now}
+	client := httpclient.NewFrom(httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		if fail.Load() {
+			return nil, errTransport
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header: http.Header{
+				"Date":          {clk.Now().Format(http.TimeFormat)},
+				"Cache-Control": {"max-age=60"},
+			},
+			Body:    io.NopCloser(strings.NewReader("body")),
+			Request: req,
+		}, nil
+	}))
+	client.Use(cache.Wrapper())
+
+	get(t, client, "http://example.com/config")
+	clk.Advance(2 * time.Minute)
+	fail.Store(true)
+
+	_, err := client.Get(context.Background(), "http://example.com/config")
+	assertEqual(t, true, errors.Is(err, errTransport), "error without stale-if-error")
+
+	got := get(t, client, "http://example.com/config", "Cache-Control", "stale-if-error=300")
+	assertEqual(t, "body", got.body, "stale body requested by the client")
+}
+
+func TestCache_Invalidation(t *testing.T) {
+	t.Parallel()
+
+	clk := newClock()
+	o := newOrigin(t, clk, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			w.Header().Set("Location", "/items/1")
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept")
+			_, _ = io.WriteString(w, r.URL.Path)
+		}
+	})
+	client, _ := newClient(o, clk)
+
+	get(t, client, o.URL+"/items")
+	get(t, client, o.URL+"/items/1", "Accept", "text/plain")
+	assertEqual(t, "httpcache; hit; ttl=60", get(t, client, o.URL+"/items").cacheStatus, "cached list")
+
+	resp, err := client.Post(context.Background(), o.URL+"/items", "text/plain", strings.NewReader("item"))
+	if err != nil {
+		t.Fatalf("call error: %v", err)
+	}
+	resp.Body.Close()
+
+	assertEqual(t, "httpcache; fwd=uri-miss; fwd-status=200", get(t, client, o.URL+"/items").cacheStatus, "invalidated list")
+	assertEqual(t, "httpcache; fwd=uri-miss; fwd-status=200",
+		get(t, client, o.URL+"/items/1", "Accept", "text/plain").cacheStatus, "invalidated location")
+}
+
+func TestCache_PartialRead(t *testing.T) {
+	t.Parallel()
+
+	clk := newClock()
+	o := newOrigin(t, clk, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, strings.Repeat("x", 1<<10))
+	})
+	cache := &httpcache.Cache{Storage: httpcache.NewMemoryStorage(1 << 20), Now: clk.Now, MaxEntrySize: 100}
+	client := httpclient.NewFrom(o.Client())
+	client.Use(cache.Wrapper())
+
+	resp, err := client.Get(context.Background(), o.URL+"/partial")
+	if err != nil {
+		t.Fatalf("call error: %v", err)
+	}
+	_, _ = io.ReadFull(resp.Body, make([]byte, 10))
+	resp.Body.Close()
+
+	assertEqual(t, "httpcache; fwd=uri-miss; fwd-status=200", get(t, client, o.URL+"/partial").cacheStatus, "partially read body")
+
+	get(t, client, o.URL+"/large")
+	assertEqual(t, "httpcache; fwd=uri-miss; fwd-status=200", get(t, client, o.URL+"/large").cacheStatus, "body larger than the limit")
+}
+
+func TestCache_DiskStorage(t *testing.T) {
+	t.Parallel()
+
+	clk := newClock()
+	o := newOrigin(t, clk, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		_, _ = io.WriteString(w, "body")
+	})
+
+	dir := t.TempDir()
+	for i := 0; i < 2; i++ {
+		storage, err := httpcache.NewDiskStorage(dir)
+		if err != nil {
+			t.Fatalf("new storage: %v", err)
+		}
+		cache := &httpcache.Cache{Storage: storage, Now: clk.Now}
+		client := httpclient.NewFrom(o.Client())
+		client.Use(cache.Wrapper())
+
+		assertEqual(t, "body", get(t, client, o.URL).body, "body")
+	}
+	assertEqual(t, 1, o.calls.Load(), "the response is cached on disk")
+}
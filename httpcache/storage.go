@@ -0,0 +1,177 @@
+package httpcache
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrNotFound is returned by Storage.Get for missing keys.
+var ErrNotFound = errors.New("httpcache: not found")
+
+// Storage stores serialized responses. Implementations must be safe for concurrent use.
+type Storage interface {
+	// Get returns the value of the key or ErrNotFound.
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte) error
+	// Delete removes the key. Missing keys are not an error.
+	Delete(key string) error
+}
+
+var (
+	_ Storage = (*MemoryStorage)(nil)
+	_ Storage = (*DiskStorage)(nil)
+)
+
+// MemoryStorage is an in-memory LRU storage limited by the total size of keys and values.
+type MemoryStorage struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	items    map[string]*list.Element
+	// order of items from the most to the least recently used
+	order *list.List
+}
+
+type memoryItem struct {
+	key   string
+	value []byte
+}
+
+func (item *memoryItem) size() int64 {
+	return int64(len(item.key) + len(item.value))
+}
+
+// NewMemoryStorage returns a storage which evicts the least recently used values
+// when the total size of keys and values exceeds maxBytes.
+// Values larger than maxBytes are not stored.
+func NewMemoryStorage(maxBytes int64) *MemoryStorage {
+	return &MemoryStorage{
+		maxBytes: maxBytes,
+		items:    map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// Get returns the value of the key and marks it as recently used.
+func (storage *MemoryStorage) Get(key string) ([]byte, error) {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	element, ok := storage.items[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	storage.order.MoveToFront(element)
+	return element.Value.(*memoryItem).value, nil
+}
+
+// Set stores a copy of the value and evicts the least recently used values if needed.
+func (storage *MemoryStorage) Set(key string, value []byte) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	storage.remove(key)
+
+	item := &memoryItem{key: key, value: bytes.Clone(value)}
+	if item.size() > storage.maxBytes {
+		return nil
+	}
+
+	storage.items[key] = storage.order.PushFront(item)
+	storage.size += item.size()
+
+	for storage.size > storage.maxBytes {
+		oldest := storage.order.Back()
+		storage.remove(oldest.Value.(*memoryItem).key)
+	}
+	return nil
+}
+
+// Delete removes the key.
+func (storage *MemoryStorage) Delete(key string) error {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	storage.remove(key)
+	return nil
+}
+
+// Size returns the total size of the stored keys and values.
+func (storage *MemoryStorage) Size() int64 {
+	storage.mu.Lock()
+	defer storage.mu.Unlock()
+
+	return storage.size
+}
+
+func (storage *MemoryStorage) remove(key string) {
+	element, ok := storage.items[key]
+	if !ok {
+		return
+	}
+	storage.order.Remove(element)
+	delete(storage.items, key)
+	storage.size -= element.Value.(*memoryItem).size()
+}
+
+// DiskStorage stores values as files in a directory.
+// File names are SHA-256 hashes of the keys, writes are atomic.
+type DiskStorage struct {
+	dir string
+}
+
+// NewDiskStorage returns a storage in the directory, creating it if needed.
+func NewDiskStorage(dir string) (*DiskStorage, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("httpcache: create storage directory: %w", err)
+	}
+	return &DiskStorage{dir: dir}, nil
+}
+
+// Get reads the value of the key.
+func (storage *DiskStorage) Get(key string) ([]byte, error) {
+	value, err := os.ReadFile(storage.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+// Set writes the value to a temporary file and renames it.
+func (storage *DiskStorage) Set(key string, value []byte) error {
+	file, err := os.CreateTemp(storage.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, errWrite := file.Write(value)
+	errClose := file.Close()
+	if err := errors.Join(errWrite, errClose); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), storage.path(key))
+}
+
+// Delete removes the file of the key.
+func (storage *DiskStorage) Delete(key string) error {
+	err := os.Remove(storage.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (storage *DiskStorage) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(storage.dir, hex.EncodeToString(sum[:]))
+}
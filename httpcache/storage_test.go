@@ -0,0 +1,73 @@
+package httpcache_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/ninedraft/httpclient/httpcache"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	t.Parallel()
+
+	storage := httpcache.NewMemoryStorage(20)
+
+	_, err := storage.Get("a")
+	assertEqual(t, true, errors.Is(err, httpcache.ErrNotFound), "missing key: %v", err)
+
+	_ = storage.Set("a", []byte("aaaa"))
+	_ = storage.Set("b", []byte("bbbb"))
+	assertEqual(t, 10, storage.Size(), "size")
+
+	// "a" becomes the most recently used, so "b" is evicted first
+	_, _ = storage.Get("a")
+	_ = storage.Set("c", []byte("cccccccccccc"))
+
+	_, err = storage.Get("b")
+	assertEqual(t, true, errors.Is(err, httpcache.ErrNotFound), "evicted key: %v", err)
+	value, err := storage.Get("a")
+	assertEqual(t, nil, err, "recently used key")
+	assertEqual(t, "aaaa", string(value), "recently used value")
+	assertEqual(t, 18, storage.Size(), "size after eviction")
+
+	_ = storage.Set("huge", make([]byte, 100))
+	_, err = storage.Get("huge")
+	assertEqual(t, true, errors.Is(err, httpcache.ErrNotFound), "value larger than the budget: %v", err)
+
+	_ = storage.Set("a", []byte("a"))
+	assertEqual(t, 15, storage.Size(), "size after replace")
+
+	_ = storage.Delete("a")
+	_ = storage.Delete("a")
+	assertEqual(t, 13, storage.Size(), "size after delete")
+}
+
+func TestDiskStorage(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	storage, err := httpcache.NewDiskStorage(dir + "/cache")
+	if err != nil {
+		t.Fatalf("new storage: %v", err)
+	}
+
+	_, err = storage.Get("https://example.com/")
+	assertEqual(t, true, errors.Is(err, httpcache.ErrNotFound), "missing key: %v", err)
+
+	assertEqual(t, nil, storage.Set("https://example.com/", []byte("first")), "set")
+	assertEqual(t, nil, storage.Set("https://example.com/", []byte("second")), "replace")
+
+	value, err := storage.Get("https://example.com/")
+	assertEqual(t, nil, err, "get")
+	assertEqual(t, "second", string(value), "value")
+
+	files, err := os.ReadDir(dir + "/cache")
+	assertEqual(t, nil, err, "read dir")
+	assertEqual(t, 1, len(files), "no temporary files are left")
+
+	assertEqual(t, nil, storage.Delete("https://example.com/"), "delete")
+	assertEqual(t, nil, storage.Delete("https://example.com/"), "delete missing key")
+	_, err = storage.Get("https://example.com/")
+	assertEqual(t, true, errors.Is(err, httpcache.ErrNotFound), "deleted key: %v", err)
+}
@@ -0,0 +1,452 @@
+// Package httpsig implements HTTP Message Signatures, as defined by RFC 9421.
+//
+// Signer signs outgoing requests and can be attached to a client with Client.Middleware:
+//
+//	signer := &httpsig.Signer{
+//		KeyID: "my-key",
+//		Key:   httpsig.Ed25519Key(privateKey),
+//	}
+//	client.Middleware = signer.Middleware()
+//
+// Verifier checks signatures of responses and requests.
+package httpsig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ninedraft/httpclient"
+)
+
+// DefaultLabel is the default label of signatures.
+const DefaultLabel = "sig1"
+
+// Signer signs requests with HTTP Message Signatures.
+type Signer struct {
+	// KeyID is sent as the keyid signature parameter.
+	KeyID string
+	Key   SigningKey
+
+	// Label names the signature in the Signature and Signature-Input fields.
+	// Default is DefaultLabel.
+	Label string
+
+	// Components are the covered component identifiers, e.g. "@method", "@target-uri",
+	// "content-digest" or `"content-type";req`. Names are lowercased, quotes are optional.
+	// Default is "@method", "@target-uri" and, for requests with bodies,
+	// "content-type" and "content-digest".
+	Components []string
+
+	// Now returns the signature creation time. Default is time.Now.
+	Now func() time.Time
+
+	// Expires sets the expiration time of signatures relative to their creation time.
+	// Zero means no expiration.
+	Expires time.Duration
+
+	// Nonce returns a unique value for every signature, if set.
+	Nonce func() string
+
+	// Tag is an application-specific tag of signatures.
+	Tag string
+
+	// IncludeAlg sends the alg signature parameter.
+	IncludeAlg bool
+}
+
+// Middleware returns a function for Client.Middleware, which signs outgoing requests.
+func (signer *Signer) Middleware() func(req *http.Request) (*http.Request, error) {
+	return func(req *http.Request) (*http.Request, error) {
+		req = req.Clone(req.Context())
+		if errSign := signer.Sign(req); errSign != nil {
+			return nil, errSign
+		}
+		return req, nil
+	}
+}
+
+// Sign adds the Signature and Signature-Input fields to the request.
+//
+// If "content-digest" is covered and the request has no Content-Digest field,
+// the SHA-256 digest of the body is added. The body is read using http.Request.GetBody.
+func (signer *Signer) Sign(req *http.Request) error {
+	defaults := []string{"@method", "@target-uri"}
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.Header.Get("Content-Type") != "" {
+			defaults = append(defaults, "content-type")
+		}
+		defaults = append(defaults, "content-digest")
+	}
+
+	digest := func() (string, error) { return requestContentDigest(req) }
+
+	return signer.sign(message{req: req}, req.Header, defaults, digest)
+}
+
+// SignResponse adds the Signature and Signature-Input fields to the response.
+// Components with the req parameter are taken from resp.Request.
+// It is useful for servers and test doubles which build responses before sending them.
+//
+// Default components are "@status" and, for responses with bodies, "content-type" and "content-digest".
+// If "content-digest" is covered and the response has no Content-Digest field,
+// the body is read into memory to compute the digest and replaced with a buffered copy.
+func (signer *Signer) SignResponse(resp *http.Response) error {
+	defaults := []string{"@status"}
+	if resp.Body != nil && resp.Body != http.NoBody {
+		if resp.Header.Get("Content-Type") != "" {
+			defaults = append(defaults, "content-type")
+		}
+		defaults = append(defaults, "content-digest")
+	}
+
+	digest := func() (string, error) {
+		if resp.Body == nil || resp.Body == http.NoBody {
+			return httpclient.DigestValue(strings.NewReader(""))
+		}
+
+		data, errRead := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if errRead != nil {
+			return "", fmt.Errorf("httpsig: content digest: %w", errRead)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+
+		return httpclient.DigestValue(bytes.NewReader(data))
+	}
+
+	return signer.sign(message{req: resp.Request, resp: resp}, resp.Header, defaults, digest)
+}
+
+func (signer *Signer) sign(msg message, header http.Header, defaults []string, digest func() (string, error)) error {
+	if signer.Key == nil {
+		return errors.New("httpsig: signing key is not set")
+	}
+
+	names := signer.Components
+	if names == nil {
+		names = defaults
+	}
+
+	components := make([]item, 0, len(names))
+	for _, name := range names {
+		component, errParse := parseComponent(name)
+		if errParse != nil {
+			return errParse
+		}
+		components = append(components, component)
+
+		_, fromRequest := component.param("req")
+		if component.value == "content-digest" && !fromRequest && header.Get("Content-Digest") == "" {
+			value, errDigest := digest()
+			if errDigest != nil {
+				return errDigest
+			}
+			header.Set("Content-Digest", value)
+		}
+	}
+
+	base, errBase := signatureBase(msg, components, signer.params())
+	if errBase != nil {
+		return errBase
+	}
+
+	signature, errSign := signer.Key.Sign([]byte(base.base))
+	if errSign != nil {
+		return fmt.Errorf("httpsig: %w", errSign)
+	}
+
+	label := signer.Label
+	if label == "" {
+		label = DefaultLabel
+	}
+
+	header.Set("Signature-Input", label+"="+base.params)
+	header.Set("Signature", label+"="+serializeBare(signature))
+
+	return nil
+}
+
+// parseComponent parses a component identifier, which may be quoted and have parameters.
+func parseComponent(name string) (item, error) {
+	if !strings.HasPrefix(name, `"`) {
+		componentName, params, _ := strings.Cut(name, ";")
+		name = strconv.Quote(strings.ToLower(componentName))
+		if params != "" {
+			name += ";" + params
+		}
+	}
+
+	component, errParse := parseItem(name)
+	if errParse != nil {
+		return item{}, fmt.Errorf("httpsig: component %s: %w", name, errParse)
+	}
+	if _, ok := component.value.(string); !ok {
+		return item{}, fmt.Errorf("httpsig: component %s is not a string", name)
+	}
+
+	return component, nil
+}
+
+func (signer *Signer) params() []param {
+	now := time.Now
+	if signer.Now != nil {
+		now = signer.Now
+	}
+	created := now().Unix()
+
+	params := []param{{name: "created", value: created}}
+	if signer.Expires > 0 {
+		params = append(params, param{name: "expires", value: created + int64(signer.Expires/time.Second)})
+	}
+	if signer.KeyID != "" {
+		params = append(params, param{name: "keyid", value: signer.KeyID})
+	}
+	if signer.IncludeAlg {
+		params = append(params, param{name: "alg", value: signer.Key.Algorithm()})
+	}
+	if signer.Nonce != nil {
+		params = append(params, param{name: "nonce", value: signer.Nonce()})
+	}
+	if signer.Tag != "" {
+		params = append(params, param{name: "tag", value: signer.Tag})
+	}
+
+	return params
+}
+
+// message is a signed request or response.
+// For responses, req is the request which the response answers.
+type message struct {
+	req  *http.Request
+	resp *http.Response
+}
+
+type base struct {
+	base   string
+	params string
+}
+
+// signatureBase builds the signature base of the message, see RFC 9421, section 2.5.
+func signatureBase(msg message, components []item, params []param) (base, error) {
+	b := strings.Builder{}
+	seen := map[string]bool{}
+
+	for _, component := range components {
+		id := serializeItem(component)
+		if seen[id] {
+			return base{}, fmt.Errorf("httpsig: component %s is covered twice", id)
+		}
+		seen[id] = true
+
+		value, errValue := msg.component(component)
+		if errValue != nil {
+			return base{}, errValue
+		}
+
+		b.WriteString(id)
+		b.WriteString(": ")
+		b.WriteString(value)
+		b.WriteByte('\n')
+	}
+
+	signatureParams := serializeInnerList(components, params)
+	b.WriteString(`"@signature-params": `)
+	b.WriteString(signatureParams)
+
+	return base{base: b.String(), params: signatureParams}, nil
+}
+
+// component returns the value of the component in the message.
+func (msg message) component(component item) (string, error) {
+	name := component.value.(string)
+
+	for _, p := range component.params {
+		switch p.name {
+		case "req", "name":
+		default:
+			return "", fmt.Errorf("httpsig: component %s: unsupported parameter %q", serializeItem(component), p.name)
+		}
+	}
+
+	_, fromRequest := component.param("req")
+	if fromRequest && msg.resp == nil {
+		return "", fmt.Errorf("httpsig: component %s: req parameter is only allowed in responses", serializeItem(component))
+	}
+
+	if strings.HasPrefix(name, "@") {
+		return msg.derived(component, name)
+	}
+
+	header, contentLength := http.Header(nil), int64(-1)
+	switch {
+	case msg.resp != nil && !fromRequest:
+		header, contentLength = msg.resp.Header, msg.resp.ContentLength
+	case msg.req != nil:
+		header, contentLength = msg.req.Header, msg.req.ContentLength
+	}
+
+	values := header.Values(name)
+	switch {
+	case len(values) > 0:
+	case name == "host" && msg.req != nil && (msg.resp == nil || fromRequest):
+		values = []string{authority(msg.req)}
+	case name == "content-length" && contentLength >= 0:
+		values = []string{strconv.FormatInt(contentLength, 10)}
+	default:
+		return "", fmt.Errorf("httpsig: component %s is not present in the message", serializeItem(component))
+	}
+
+	trimmed := make([]string, 0, len(values))
+	for _, value := range values {
+		trimmed = append(trimmed, strings.TrimSpace(value))
+	}
+	return strings.Join(trimmed, ", "), nil
+}
+
+func (msg message) derived(component item, name string) (string, error) {
+	if name == "@status" {
+		if msg.resp == nil {
+			return "", errors.New("httpsig: component \"@status\" is only allowed in responses")
+		}
+		return strconv.Itoa(msg.resp.StatusCode), nil
+	}
+
+	req := msg.req
+	if req == nil {
+		return "", fmt.Errorf("httpsig: component %s requires the request", serializeItem(component))
+	}
+
+	switch name {
+	case "@method":
+		return req.Method, nil
+	case "@target-uri":
+		return targetURI(req).String(), nil
+	case "@authority":
+		return authority(req), nil
+	case "@scheme":
+		return targetURI(req).Scheme, nil
+	case "@request-target":
+		return req.URL.RequestURI(), nil
+	case "@path":
+		if path := req.URL.EscapedPath(); path != "" {
+			return path, nil
+		}
+		return "/", nil
+	case "@query":
+		return "?" + req.URL.RawQuery, nil
+	case "@query-param":
+		return queryParam(component, req.URL)
+	default:
+		return "", fmt.Errorf("httpsig: unsupported component %s", serializeItem(component))
+	}
+}
+
+func queryParam(component item, u *url.URL) (string, error) {
+	name, ok := component.param("name")
+	paramName, isString := name.(string)
+	if !ok || !isString {
+		return "", errors.New(`httpsig: component "@query-param" requires the name parameter`)
+	}
+
+	query, errQuery := url.ParseQuery(u.RawQuery)
+	if errQuery != nil {
+		return "", fmt.Errorf("httpsig: %w", errQuery)
+	}
+
+	// the name parameter is encoded like the value
+	decodedName, errName := url.QueryUnescape(paramName)
+	if errName != nil {
+		return "", fmt.Errorf("httpsig: %w", errName)
+	}
+
+	values := query[decodedName]
+	if len(values) != 1 {
+		return "", fmt.Errorf("httpsig: query parameter %q must occur exactly once", paramName)
+	}
+
+	return percentEncode(values[0]), nil
+}
+
+// percentEncode encodes the value with the application/x-www-form-urlencoded percent-encode set,
+// but with spaces encoded as "%20", as required by RFC 9421, section 2.2.8.
+func percentEncode(s string) string {
+	const upperHex = "0123456789ABCDEF"
+
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '*', c == '-', c == '.', c == '_':
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(upperHex[c>>4])
+			b.WriteByte(upperHex[c&0x0f])
+		}
+	}
+	return b.String()
+}
+
+// targetURI returns the absolute URL of the request.
+// Server requests only contain the request target, so the scheme and host are restored.
+func targetURI(req *http.Request) *url.URL {
+	u := *req.URL
+	u.User, u.Fragment, u.RawFragment = nil, "", ""
+
+	if u.Scheme == "" {
+		u.Scheme = "http"
+		if req.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+	if u.Host == "" {
+		u.Host = req.Host
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+
+	return &u
+}
+
+// authority returns the lowercase host of the request without the default port.
+func authority(req *http.Request) string {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	host = strings.ToLower(host)
+
+	switch scheme := targetURI(req).Scheme; {
+	case scheme == "https" && strings.HasSuffix(host, ":443"):
+		return strings.TrimSuffix(host, ":443")
+	case scheme == "http" && strings.HasSuffix(host, ":80"):
+		return strings.TrimSuffix(host, ":80")
+	}
+	return host
+}
+
+// requestContentDigest returns the Content-Digest field value of the request body.
+func requestContentDigest(req *http.Request) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return httpclient.DigestValue(strings.NewReader(""))
+	}
+	if req.GetBody == nil {
+		return "", fmt.Errorf("httpsig: content digest: %w", httpclient.ErrBodyNotRewindable)
+	}
+
+	body, errBody := req.GetBody()
+	if errBody != nil {
+		return "", fmt.Errorf("httpsig: content digest: %w", errBody)
+	}
+	defer body.Close()
+
+	return httpclient.DigestValue(body)
+}
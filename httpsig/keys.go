@@ -0,0 +1,226 @@
+package httpsig
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Algorithms registered by RFC 9421.
+const (
+	AlgorithmEd25519         = "ed25519"
+	AlgorithmECDSAP256SHA256 = "ecdsa-p256-sha256"
+	AlgorithmECDSAP384SHA384 = "ecdsa-p384-sha384"
+	AlgorithmRSAPSSSHA512    = "rsa-pss-sha512"
+	AlgorithmHMACSHA256      = "hmac-sha256"
+)
+
+// SigningKey creates signatures of signature bases.
+type SigningKey interface {
+	// Algorithm returns the name of the signature algorithm.
+	Algorithm() string
+	Sign(base []byte) ([]byte, error)
+}
+
+// VerifyingKey verifies signatures of signature bases.
+type VerifyingKey interface {
+	// Algorithm returns the name of the signature algorithm.
+	Algorithm() string
+	Verify(base, signature []byte) error
+}
+
+// Key is a symmetric key, which both signs and verifies.
+type Key interface {
+	SigningKey
+	VerifyingKey
+}
+
+// ErrInvalidSignature is returned when a signature doesn't match the signature base.
+var ErrInvalidSignature = errors.New("httpsig: invalid signature")
+
+// Ed25519Key returns a signing key using the EdDSA algorithm with the Ed25519 curve.
+func Ed25519Key(key ed25519.PrivateKey) SigningKey {
+	return ed25519Key(key)
+}
+
+// Ed25519PublicKey returns a verifying key for Ed25519 signatures.
+func Ed25519PublicKey(key ed25519.PublicKey) VerifyingKey {
+	return ed25519PublicKey(key)
+}
+
+type ed25519Key ed25519.PrivateKey
+
+func (key ed25519Key) Algorithm() string { return AlgorithmEd25519 }
+
+func (key ed25519Key) Sign(base []byte) ([]byte, error) {
+	return ed25519.Sign(ed25519.PrivateKey(key), base), nil
+}
+
+type ed25519PublicKey ed25519.PublicKey
+
+func (key ed25519PublicKey) Algorithm() string { return AlgorithmEd25519 }
+
+func (key ed25519PublicKey) Verify(base, signature []byte) error {
+	if !ed25519.Verify(ed25519.PublicKey(key), base, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ECDSAKey returns a signing key using ECDSA with the P-256 curve and SHA-256
+// or the P-384 curve and SHA-384, depending on the key curve.
+// Signatures are encoded as the concatenation of r and s.
+func ECDSAKey(key *ecdsa.PrivateKey) SigningKey {
+	return &ecdsaKey{key: key}
+}
+
+// ECDSAPublicKey returns a verifying key for ECDSA signatures.
+func ECDSAPublicKey(key *ecdsa.PublicKey) VerifyingKey {
+	return &ecdsaPublicKey{key: key}
+}
+
+type ecdsaKey struct {
+	key *ecdsa.PrivateKey
+}
+
+func (key *ecdsaKey) Algorithm() string { return ecdsaAlgorithm(key.key.Curve) }
+
+func (key *ecdsaKey) Sign(base []byte) ([]byte, error) {
+	digest, size, errDigest := ecdsaDigest(key.key.Curve, base)
+	if errDigest != nil {
+		return nil, errDigest
+	}
+
+	r, s, errSign := ecdsa.Sign(rand.Reader, key.key, digest)
+	if errSign != nil {
+		return nil, errSign
+	}
+
+	signature := make([]byte, 2*size)
+	r.FillBytes(signature[:size])
+	s.FillBytes(signature[size:])
+
+	return signature, nil
+}
+
+type ecdsaPublicKey struct {
+	key *ecdsa.PublicKey
+}
+
+func (key *ecdsaPublicKey) Algorithm() string { return ecdsaAlgorithm(key.key.Curve) }
+
+func (key *ecdsaPublicKey) Verify(base, signature []byte) error {
+	digest, size, errDigest := ecdsaDigest(key.key.Curve, base)
+	if errDigest != nil {
+		return errDigest
+	}
+	if len(signature) != 2*size {
+		return ErrInvalidSignature
+	}
+
+	r := new(big.Int).SetBytes(signature[:size])
+	s := new(big.Int).SetBytes(signature[size:])
+	if !ecdsa.Verify(key.key, digest, r, s) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func ecdsaAlgorithm(curve elliptic.Curve) string {
+	switch curve {
+	case elliptic.P256():
+		return AlgorithmECDSAP256SHA256
+	case elliptic.P384():
+		return AlgorithmECDSAP384SHA384
+	default:
+		return ""
+	}
+}
+
+// ecdsaDigest returns the hash of the data and the size of the signature components.
+func ecdsaDigest(curve elliptic.Curve, data []byte) ([]byte, int, error) {
+	switch curve {
+	case elliptic.P256():
+		digest := sha256.Sum256(data)
+		return digest[:], 32, nil
+	case elliptic.P384():
+		digest := sha512.Sum384(data)
+		return digest[:], 48, nil
+	default:
+		return nil, 0, fmt.Errorf("httpsig: unsupported ECDSA curve %s", curve.Params().Name)
+	}
+}
+
+// RSAPSSKey returns a signing key using RSASSA-PSS with SHA-512.
+func RSAPSSKey(key *rsa.PrivateKey) SigningKey {
+	return &rsaPSSKey{key: key}
+}
+
+// RSAPSSPublicKey returns a verifying key for RSASSA-PSS signatures.
+func RSAPSSPublicKey(key *rsa.PublicKey) VerifyingKey {
+	return &rsaPSSPublicKey{key: key}
+}
+
+type rsaPSSKey struct {
+	key *rsa.PrivateKey
+}
+
+func (key *rsaPSSKey) Algorithm() string { return AlgorithmRSAPSSSHA512 }
+
+func (key *rsaPSSKey) Sign(base []byte) ([]byte, error) {
+	digest := sha512.Sum512(base)
+	return rsa.SignPSS(rand.Reader, key.key, crypto.SHA512, digest[:], &rsa.PSSOptions{
+		SaltLength: 64,
+		Hash:       crypto.SHA512,
+	})
+}
+
+type rsaPSSPublicKey struct {
+	key *rsa.PublicKey
+}
+
+func (key *rsaPSSPublicKey) Algorithm() string { return AlgorithmRSAPSSSHA512 }
+
+func (key *rsaPSSPublicKey) Verify(base, signature []byte) error {
+	digest := sha512.Sum512(base)
+	errVerify := rsa.VerifyPSS(key.key, crypto.SHA512, digest[:], signature, &rsa.PSSOptions{
+		SaltLength: rsa.PSSSaltLengthAuto,
+		Hash:       crypto.SHA512,
+	})
+	if errVerify != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// HMACKey returns a shared key using HMAC with SHA-256.
+func HMACKey(secret []byte) Key {
+	return hmacKey(secret)
+}
+
+type hmacKey []byte
+
+func (key hmacKey) Algorithm() string { return AlgorithmHMACSHA256 }
+
+func (key hmacKey) Sign(base []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(base)
+	return mac.Sum(nil), nil
+}
+
+func (key hmacKey) Verify(base, signature []byte) error {
+	expected, _ := key.Sign(base)
+	if !hmac.Equal(expected, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package httpsig_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+
+	"github.com/ninedraft/httpclient/httpsig"
+)
+
+func TestKeys(t *testing.T) {
+	t.Parallel()
+
+	publicEd25519, privateEd25519, _ := ed25519.GenerateKey(rand.Reader)
+	p256, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	hmacKey := httpsig.HMACKey([]byte("secret"))
+
+	tcs := []struct {
+		algorithm string
+		signing   httpsig.SigningKey
+		verifying httpsig.VerifyingKey
+	}{
+		{httpsig.AlgorithmEd25519, httpsig.Ed25519Key(privateEd25519), httpsig.Ed25519PublicKey(publicEd25519)},
+		{httpsig.AlgorithmECDSAP256SHA256, httpsig.ECDSAKey(p256), httpsig.ECDSAPublicKey(&p256.PublicKey)},
+		{httpsig.AlgorithmECDSAP384SHA384, httpsig.ECDSAKey(p384), httpsig.ECDSAPublicKey(&p384.PublicKey)},
+		{httpsig.AlgorithmRSAPSSSHA512, httpsig.RSAPSSKey(rsaKey), httpsig.RSAPSSPublicKey(&rsaKey.PublicKey)},
+		{httpsig.AlgorithmHMACSHA256, hmacKey, hmacKey},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.algorithm, func(t *testing.T) {
+			t.Parallel()
+
+			assertEqual(t, tc.algorithm, tc.signing.Algorithm(), "signing key algorithm")
+			assertEqual(t, tc.algorithm, tc.verifying.Algorithm(), "verifying key algorithm")
+
+			base := []byte(`"@method": GET`)
+			signature, err := tc.signing.Sign(base)
+			if err != nil {
+				t.Fatalf("sign: %v", err)
+			}
+
+			if err := tc.verifying.Verify(base, signature); err != nil {
+				t.Errorf("verify: %v", err)
+			}
+
+			err = tc.verifying.Verify([]byte(`"@method": POST`), signature)
+			assertEqual(t, true, errors.Is(err, httpsig.ErrInvalidSignature), "unexpected error: %v", err)
+		})
+	}
+}
@@ -0,0 +1,386 @@
+package httpsig
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// This file implements the subset of Structured Field Values (RFC 8941)
+// used by the Signature, Signature-Input and Content-Digest fields:
+// dictionaries of inner lists and bare items with parameters.
+
+// token is a structured field token, it is serialized without quotes.
+type token string
+
+type param struct {
+	name  string
+	value any
+}
+
+type item struct {
+	value  any
+	params []param
+}
+
+type member struct {
+	name string
+	// inner is set for inner list members, value is set for bare items.
+	inner  []item
+	isList bool
+	value  any
+	params []param
+}
+
+func (it item) param(name string) (any, bool) {
+	return findParam(it.params, name)
+}
+
+func (m member) param(name string) (any, bool) {
+	return findParam(m.params, name)
+}
+
+func findParam(params []param, name string) (any, bool) {
+	for _, p := range params {
+		if p.name == name {
+			return p.value, true
+		}
+	}
+	return nil, false
+}
+
+// parseDictionary parses the values of a dictionary field.
+// Multiple field lines are combined as required by RFC 8941.
+func parseDictionary(values []string) ([]member, error) {
+	p := &sfParser{s: strings.Join(values, ", ")}
+	p.skipSpaces()
+
+	var members []member
+	for p.s != "" {
+		name, errKey := p.key()
+		if errKey != nil {
+			return nil, errKey
+		}
+
+		m := member{name: name, value: true}
+		if p.consume('=') {
+			var errValue error
+			if p.peek() == '(' {
+				m.isList = true
+				m.inner, errValue = p.innerList()
+			} else {
+				m.value, errValue = p.bareItem()
+			}
+			if errValue != nil {
+				return nil, errValue
+			}
+		}
+
+		var errParams error
+		m.params, errParams = p.params()
+		if errParams != nil {
+			return nil, errParams
+		}
+
+		// later members replace earlier ones with the same name
+		members = removeMember(members, name)
+		members = append(members, m)
+
+		p.skipOWS()
+		if p.s == "" {
+			break
+		}
+		if !p.consume(',') {
+			return nil, p.errorf("expected a comma")
+		}
+		p.skipOWS()
+		if p.s == "" {
+			return nil, p.errorf("trailing comma")
+		}
+	}
+
+	return members, nil
+}
+
+func removeMember(members []member, name string) []member {
+	for i, m := range members {
+		if m.name == name {
+			return append(members[:i], members[i+1:]...)
+		}
+	}
+	return members
+}
+
+// parseItem parses a single item with parameters, e.g. `"content-type";req`.
+func parseItem(s string) (item, error) {
+	p := &sfParser{s: s}
+
+	value, errValue := p.bareItem()
+	if errValue != nil {
+		return item{}, errValue
+	}
+	params, errParams := p.params()
+	if errParams != nil {
+		return item{}, errParams
+	}
+	if p.s != "" {
+		return item{}, p.errorf("unexpected trailing characters")
+	}
+
+	return item{value: value, params: params}, nil
+}
+
+type sfParser struct {
+	s string
+}
+
+func (p *sfParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("structured field: "+format+" at %q", append(args, p.s)...)
+}
+
+func (p *sfParser) peek() byte {
+	if p.s == "" {
+		return 0
+	}
+	return p.s[0]
+}
+
+func (p *sfParser) consume(c byte) bool {
+	if p.peek() != c {
+		return false
+	}
+	p.s = p.s[1:]
+	return true
+}
+
+func (p *sfParser) skipSpaces() {
+	p.s = strings.TrimLeft(p.s, " ")
+}
+
+func (p *sfParser) skipOWS() {
+	p.s = strings.TrimLeft(p.s, " \t")
+}
+
+func (p *sfParser) key() (string, error) {
+	c := p.peek()
+	if !(c >= 'a' && c <= 'z') && c != '*' {
+		return "", p.errorf("invalid key")
+	}
+
+	end := 1
+	for end < len(p.s) && isKeyChar(p.s[end]) {
+		end++
+	}
+
+	key := p.s[:end]
+	p.s = p.s[end:]
+	return key, nil
+}
+
+func isKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("_-.*", c) >= 0
+}
+
+func (p *sfParser) params() ([]param, error) {
+	var params []param
+	for p.consume(';') {
+		p.skipSpaces()
+
+		name, errKey := p.key()
+		if errKey != nil {
+			return nil, errKey
+		}
+
+		var value any = true
+		if p.consume('=') {
+			var errValue error
+			value, errValue = p.bareItem()
+			if errValue != nil {
+				return nil, errValue
+			}
+		}
+
+		params = append(params, param{name: name, value: value})
+	}
+	return params, nil
+}
+
+func (p *sfParser) innerList() ([]item, error) {
+	if !p.consume('(') {
+		return nil, p.errorf("expected an inner list")
+	}
+
+	var items []item
+	for {
+		p.skipSpaces()
+		if p.consume(')') {
+			return items, nil
+		}
+
+		value, errValue := p.bareItem()
+		if errValue != nil {
+			return nil, errValue
+		}
+		params, errParams := p.params()
+		if errParams != nil {
+			return nil, errParams
+		}
+		items = append(items, item{value: value, params: params})
+
+		if c := p.peek(); c != ' ' && c != ')' {
+			return nil, p.errorf("unterminated inner list")
+		}
+	}
+}
+
+func (p *sfParser) bareItem() (any, error) {
+	c := p.peek()
+	switch {
+	case c == '"':
+		return p.string()
+	case c == ':':
+		return p.byteSequence()
+	case c == '?':
+		return p.boolean()
+	case c == '-' || c >= '0' && c <= '9':
+		return p.integer()
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '*':
+		return p.token(), nil
+	default:
+		return nil, p.errorf("invalid item")
+	}
+}
+
+func (p *sfParser) string() (string, error) {
+	b := strings.Builder{}
+	for i := 1; i < len(p.s); i++ {
+		switch c := p.s[i]; {
+		case c == '\\':
+			i++
+			if i == len(p.s) || (p.s[i] != '"' && p.s[i] != '\\') {
+				return "", p.errorf("invalid escape")
+			}
+			b.WriteByte(p.s[i])
+		case c == '"':
+			p.s = p.s[i+1:]
+			return b.String(), nil
+		case c < 0x20 || c > 0x7e:
+			return "", p.errorf("invalid string character")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *sfParser) byteSequence() ([]byte, error) {
+	end := strings.IndexByte(p.s[1:], ':')
+	if end < 0 {
+		return nil, p.errorf("unterminated byte sequence")
+	}
+
+	data, errDecode := base64.StdEncoding.DecodeString(p.s[1 : end+1])
+	if errDecode != nil {
+		return nil, p.errorf("invalid byte sequence: %v", errDecode)
+	}
+
+	p.s = p.s[end+2:]
+	return data, nil
+}
+
+func (p *sfParser) boolean() (bool, error) {
+	if len(p.s) < 2 || (p.s[1] != '0' && p.s[1] != '1') {
+		return false, p.errorf("invalid boolean")
+	}
+	value := p.s[1] == '1'
+	p.s = p.s[2:]
+	return value, nil
+}
+
+func (p *sfParser) integer() (int64, error) {
+	end := 0
+	if p.peek() == '-' {
+		end++
+	}
+	for end < len(p.s) && p.s[end] >= '0' && p.s[end] <= '9' {
+		end++
+	}
+
+	if end < len(p.s) && p.s[end] == '.' {
+		return 0, p.errorf("decimals are not supported")
+	}
+
+	n, errParse := strconv.ParseInt(p.s[:end], 10, 64)
+	if errParse != nil {
+		return 0, p.errorf("invalid integer")
+	}
+	p.s = p.s[end:]
+	return n, nil
+}
+
+func (p *sfParser) token() token {
+	end := 1
+	for end < len(p.s) && isTokenChar(p.s[end]) {
+		end++
+	}
+	t := p.s[:end]
+	p.s = p.s[end:]
+	return token(t)
+}
+
+func isTokenChar(c byte) bool {
+	return c > 0x20 && c < 0x7f && strings.IndexByte(`"(),;<=>?@[\]{}`, c) < 0
+}
+
+func serializeInnerList(items []item, params []param) string {
+	b := strings.Builder{}
+	b.WriteByte('(')
+	for i, it := range items {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(serializeItem(it))
+	}
+	b.WriteByte(')')
+	b.WriteString(serializeParams(params))
+	return b.String()
+}
+
+func serializeItem(it item) string {
+	return serializeBare(it.value) + serializeParams(it.params)
+}
+
+func serializeParams(params []param) string {
+	b := strings.Builder{}
+	for _, p := range params {
+		b.WriteByte(';')
+		b.WriteString(p.name)
+		if value, ok := p.value.(bool); !ok || !value {
+			b.WriteByte('=')
+			b.WriteString(serializeBare(p.value))
+		}
+	}
+	return b.String()
+}
+
+func serializeBare(value any) string {
+	switch v := value.(type) {
+	case string:
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+	case token:
+		return string(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		if v {
+			return "?1"
+		}
+		return "?0"
+	case []byte:
+		return ":" + base64.StdEncoding.EncodeToString(v) + ":"
+	default:
+		panic(errors.New("structured field: unsupported value type"))
+	}
+}
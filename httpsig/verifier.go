@@ -0,0 +1,226 @@
+package httpsig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ninedraft/httpclient"
+)
+
+// ErrNoSignature is returned when the message has no signature to verify.
+var ErrNoSignature = errors.New("httpsig: message is not signed")
+
+// ErrExpired is returned when the signature is expired or too old.
+var ErrExpired = errors.New("httpsig: signature is expired")
+
+// Verifier verifies HTTP Message Signatures.
+type Verifier struct {
+	// Keys returns the verifying key by the keyid signature parameter.
+	Keys func(keyID string) (VerifyingKey, error)
+
+	// Label selects the signature to verify.
+	// Default is the first signature of the Signature-Input field.
+	Label string
+
+	// Required are the component identifiers which must be covered by the signature,
+	// e.g. "@status" or "content-digest".
+	Required []string
+
+	// MaxAge limits the age of signatures. Zero means no limit.
+	MaxAge time.Duration
+
+	// Now returns the current time. Default is time.Now.
+	Now func() time.Time
+}
+
+// VerifyRequest verifies the signature of the request received by a server.
+func (verifier *Verifier) VerifyRequest(req *http.Request) error {
+	return verifier.verify(message{req: req}, req.Header, &req.Body)
+}
+
+// VerifyResponse verifies the signature of the response.
+// Components with the req parameter are taken from resp.Request.
+//
+// If "content-digest" is covered, the response body is read into memory
+// to verify the digest and then replaced with a buffered copy.
+func (verifier *Verifier) VerifyResponse(resp *http.Response) error {
+	return verifier.verify(message{req: resp.Request, resp: resp}, resp.Header, &resp.Body)
+}
+
+// Wrapper returns a wrapper which verifies signatures of responses.
+// Responses with invalid signatures are closed and the verification error is returned.
+func (verifier *Verifier) Wrapper() httpclient.Wrapper {
+	return func(next httpclient.Doer) httpclient.Doer {
+		return httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.Do(req)
+			if err != nil {
+				return resp, err
+			}
+
+			if resp.Request == nil {
+				resp.Request = req
+			}
+			if errVerify := verifier.VerifyResponse(resp); errVerify != nil {
+				_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+				_ = resp.Body.Close()
+				return nil, errVerify
+			}
+
+			return resp, nil
+		})
+	}
+}
+
+func (verifier *Verifier) verify(msg message, header http.Header, body *io.ReadCloser) error {
+	input, signature, errFind := verifier.find(header)
+	if errFind != nil {
+		return errFind
+	}
+
+	if errRequired := verifier.checkRequired(input.inner); errRequired != nil {
+		return errRequired
+	}
+	if errTime := verifier.checkTime(input); errTime != nil {
+		return errTime
+	}
+
+	keyID, _ := input.param("keyid")
+	keyIDValue, _ := keyID.(string)
+	if verifier.Keys == nil {
+		return errors.New("httpsig: verifying keys are not set")
+	}
+	key, errKey := verifier.Keys(keyIDValue)
+	if errKey != nil {
+		return fmt.Errorf("httpsig: key %q: %w", keyIDValue, errKey)
+	}
+
+	if alg, ok := input.param("alg"); ok && alg != key.Algorithm() {
+		return fmt.Errorf("httpsig: signature algorithm %v doesn't match the key algorithm %s", alg, key.Algorithm())
+	}
+
+	for _, component := range input.inner {
+		if _, ok := component.value.(string); !ok {
+			return fmt.Errorf("httpsig: component %s is not a string", serializeItem(component))
+		}
+	}
+
+	base, errBase := signatureBase(msg, input.inner, input.params)
+	if errBase != nil {
+		return errBase
+	}
+
+	if errVerify := key.Verify([]byte(base.base), signature); errVerify != nil {
+		return fmt.Errorf("httpsig: signature %s: %w", input.name, errVerify)
+	}
+
+	for _, component := range input.inner {
+		_, fromRequest := component.param("req")
+		if component.value == "content-digest" && !fromRequest {
+			return verifyBodyDigest(header, body)
+		}
+	}
+
+	return nil
+}
+
+// find returns the selected Signature-Input member and its signature.
+func (verifier *Verifier) find(header http.Header) (member, []byte, error) {
+	inputs, errInputs := parseDictionary(header.Values("Signature-Input"))
+	if errInputs != nil {
+		return member{}, nil, fmt.Errorf("httpsig: Signature-Input: %w", errInputs)
+	}
+	signatures, errSignatures := parseDictionary(header.Values("Signature"))
+	if errSignatures != nil {
+		return member{}, nil, fmt.Errorf("httpsig: Signature: %w", errSignatures)
+	}
+
+	for _, input := range inputs {
+		if verifier.Label != "" && input.name != verifier.Label {
+			continue
+		}
+		if !input.isList {
+			return member{}, nil, fmt.Errorf("httpsig: Signature-Input %s is not an inner list", input.name)
+		}
+
+		for _, signature := range signatures {
+			if signature.name != input.name {
+				continue
+			}
+			value, ok := signature.value.([]byte)
+			if !ok || signature.isList {
+				return member{}, nil, fmt.Errorf("httpsig: Signature %s is not a byte sequence", input.name)
+			}
+			return input, value, nil
+		}
+
+		return member{}, nil, fmt.Errorf("%w: no signature %s", ErrNoSignature, input.name)
+	}
+
+	return member{}, nil, ErrNoSignature
+}
+
+func (verifier *Verifier) checkRequired(covered []item) error {
+	for _, name := range verifier.Required {
+		required, errParse := parseComponent(name)
+		if errParse != nil {
+			return errParse
+		}
+
+		id := serializeItem(required)
+		found := false
+		for _, component := range covered {
+			found = found || serializeItem(component) == id
+		}
+		if !found {
+			return fmt.Errorf("httpsig: required component %s is not covered", id)
+		}
+	}
+	return nil
+}
+
+func (verifier *Verifier) checkTime(input member) error {
+	now := time.Now()
+	if verifier.Now != nil {
+		now = verifier.Now()
+	}
+
+	if expires, ok := input.param("expires"); ok {
+		if at, isInt := expires.(int64); !isInt || now.After(time.Unix(at, 0)) {
+			return ErrExpired
+		}
+	}
+
+	if verifier.MaxAge > 0 {
+		created, ok := input.param("created")
+		at, isInt := created.(int64)
+		if !ok || !isInt || now.Sub(time.Unix(at, 0)) > verifier.MaxAge {
+			return ErrExpired
+		}
+	}
+
+	return nil
+}
+
+// verifyBodyDigest reads the body and verifies its Content-Digest.
+// The body is replaced with a buffered copy.
+func verifyBodyDigest(header http.Header, body *io.ReadCloser) error {
+	var content []byte
+	if *body != nil && *body != http.NoBody {
+		data, errRead := io.ReadAll(*body)
+		_ = (*body).Close()
+		if errRead != nil {
+			return fmt.Errorf("httpsig: read body: %w", errRead)
+		}
+		content = data
+		*body = io.NopCloser(bytes.NewReader(data))
+	}
+
+	if errDigest := httpclient.VerifyDigest("Content-Digest", header.Values("Content-Digest"), bytes.NewReader(content)); errDigest != nil {
+		return fmt.Errorf("httpsig: %w", errDigest)
+	}
+	return nil
+}
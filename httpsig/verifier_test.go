@@ -0,0 +1,155 @@
+package httpsig_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ninedraft/httpclient"
+	"github.com/ninedraft/httpclient/httpsig"
+)
+
+// signedServer returns a doer which responds with signed responses.
+// The tamper function can modify responses after signing.
+func signedServer(t *testing.T, signer *httpsig.Signer, tamper func(resp *http.Response)) httpclient.Doer {
+	return httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		resp := &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{"Content-Type": {"application/json"}},
+			Body:          io.NopCloser(strings.NewReader(`{"name":"gopher"}`)),
+			ContentLength: -1,
+			Request:       req,
+		}
+		if err := signer.SignResponse(resp); err != nil {
+			t.Errorf("sign response: %v", err)
+		}
+		if tamper != nil {
+			tamper(resp)
+		}
+		return resp, nil
+	})
+}
+
+func TestVerifier_Wrapper(t *testing.T) {
+	t.Parallel()
+
+	key := httpsig.HMACKey([]byte("shared-secret"))
+	signer := &httpsig.Signer{
+		KeyID:      "server",
+		Key:        key,
+		Components: []string{"@status", "content-type", "content-digest", `"@method";req`},
+		IncludeAlg: true,
+	}
+	verifier := &httpsig.Verifier{
+		Keys: func(keyID string) (httpsig.VerifyingKey, error) {
+			if keyID != "server" {
+				return nil, errors.New("unknown key")
+			}
+			return key, nil
+		},
+		Required: []string{"@status", "content-digest"},
+		MaxAge:   time.Minute,
+	}
+
+	tcs := []struct {
+		name   string
+		tamper func(resp *http.Response)
+		err    error
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name: "status",
+			tamper: func(resp *http.Response) {
+				resp.StatusCode = http.StatusCreated
+			},
+			err: httpsig.ErrInvalidSignature,
+		},
+		{
+			name: "body",
+			tamper: func(resp *http.Response) {
+				resp.Body = io.NopCloser(strings.NewReader(`{"name":"hacker"}`))
+			},
+		},
+		{
+			name: "unsigned",
+			tamper: func(resp *http.Response) {
+				resp.Header.Del("Signature")
+				resp.Header.Del("Signature-Input")
+			},
+			err: httpsig.ErrNoSignature,
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := httpclient.NewFrom(signedServer(t, signer, tc.tamper))
+			client.Use(verifier.Wrapper())
+
+			resp, err := client.Get(context.Background(), "https://example.com/items")
+			switch {
+			case tc.name == "valid":
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				defer resp.Body.Close()
+
+				body, _ := io.ReadAll(resp.Body)
+				assertEqual(t, `{"name":"gopher"}`, string(body), "response body")
+			case tc.err != nil:
+				assertEqual(t, true, errors.Is(err, tc.err), "unexpected error: %v", err)
+			default:
+				// body tampering is detected by the content digest
+				mismatch := &httpclient.DigestMismatchError{}
+				assertEqual(t, true, errors.As(err, &mismatch), "unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifier_Required(t *testing.T) {
+	t.Parallel()
+
+	key := httpsig.HMACKey([]byte("shared-secret"))
+	signer := &httpsig.Signer{Key: key, Components: []string{"@status"}}
+	verifier := &httpsig.Verifier{
+		Keys:     func(string) (httpsig.VerifyingKey, error) { return key, nil },
+		Required: []string{"content-digest"},
+	}
+
+	client := httpclient.NewFrom(signedServer(t, signer, nil))
+	client.Use(verifier.Wrapper())
+
+	_, err := client.Get(context.Background(), "https://example.com/")
+	if err == nil || !strings.Contains(err.Error(), `required component "content-digest" is not covered`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestVerifier_Expired(t *testing.T) {
+	t.Parallel()
+
+	key := httpsig.HMACKey([]byte("shared-secret"))
+	signer := &httpsig.Signer{
+		Key:     key,
+		Now:     func() time.Time { return time.Now().Add(-time.Hour) },
+		Expires: time.Minute,
+	}
+	verifier := &httpsig.Verifier{
+		Keys: func(string) (httpsig.VerifyingKey, error) { return key, nil },
+	}
+
+	client := httpclient.NewFrom(signedServer(t, signer, nil))
+	client.Use(verifier.Wrapper())
+
+	_, err := client.Get(context.Background(), "https://example.com/")
+	assertEqual(t, true, errors.Is(err, httpsig.ErrExpired), "unexpected error: %v", err)
+}
@@ -1,37 +1,60 @@
 package httpclient
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"net/http"
 )
 
 // PostJSON makes a POST request to the given address with JSON-encoded body.
-func (client *Client) PostJSON(ctx context.Context, addr string, obj any) (*http.Response, error) {
-	return client.doJSON(ctx, http.MethodPost, addr, obj)
+func (client *Client) PostJSON(ctx context.Context, addr string, obj any, opts ...RequestOption) (*http.Response, error) {
+	return client.PostAs(ctx, addr, JSONCodec{}, obj, opts...)
 }
 
 // PutJSON makes a PUT request to the given address with JSON-encoded body.
-func (client *Client) PutJSON(ctx context.Context, addr string, obj any) (*http.Response, error) {
-	return client.doJSON(ctx, http.MethodPut, addr, obj)
+func (client *Client) PutJSON(ctx context.Context, addr string, obj any, opts ...RequestOption) (*http.Response, error) {
+	return client.PutAs(ctx, addr, JSONCodec{}, obj, opts...)
 }
 
 // PatchJSON makes a PATCH request to the given address with JSON-encoded body.
-func (client *Client) PatchJSON(ctx context.Context, addr string, obj any) (*http.Response, error) {
-	return client.doJSON(ctx, http.MethodPatch, addr, obj)
+func (client *Client) PatchJSON(ctx context.Context, addr string, obj any, opts ...RequestOption) (*http.Response, error) {
+	return client.PatchAs(ctx, addr, JSONCodec{}, obj, opts...)
 }
 
 // QueryJSON makes a QUERY request to the given address with JSON-encoded body.
-func (client *Client) QueryJSON(ctx context.Context, addr string, obj any) (*http.Response, error) {
-	return client.doJSON(ctx, methodQuery, addr, obj)
+func (client *Client) QueryJSON(ctx context.Context, addr string, obj any, opts ...RequestOption) (*http.Response, error) {
+	return client.QueryAs(ctx, addr, JSONCodec{}, obj, opts...)
 }
 
-func (client *Client) doJSON(ctx context.Context, method, addr string, obj any) (*http.Response, error) {
-	body, errJSON := json.Marshal(obj)
-	if errJSON != nil {
-		return nil, errJSON
-	}
+// GetJSON makes a GET request to the given address and decodes the JSON response into a value of type T.
+// Responses with non-2xx status codes are reported as *StatusError.
+func GetJSON[T any](ctx context.Context, client *Client, addr string, opts ...RequestOption) (T, error) {
+	return DecodeJSON[T](client.Get(ctx, addr, opts...))
+}
+
+// DeleteJSON makes a DELETE request to the given address and decodes the JSON response into a value of type T.
+// Responses with non-2xx status codes are reported as *StatusError.
+func DeleteJSON[T any](ctx context.Context, client *Client, addr string, opts ...RequestOption) (T, error) {
+	return DecodeJSON[T](client.Delete(ctx, addr, opts...))
+}
+
+// DoJSON makes a request with the given method and JSON-encoded body
+// and decodes the JSON response into a value of type Resp.
+// Responses with non-2xx status codes are reported as *StatusError.
+func DoJSON[Req, Resp any](ctx context.Context, client *Client, method, addr string, obj Req, opts ...RequestOption) (Resp, error) {
+	return DecodeJSON[Resp](client.NewRequest(method, addr).JSON(obj).Apply(opts...).Do(ctx))
+}
+
+// DecodeJSON decodes the JSON-encoded response body into a value of type T.
+// It accepts the results of a request call as is, so it can be chained with any of the Client methods.
+// The response body is always drained and closed.
+// Responses with non-2xx status codes are reported as *StatusError.
+// An empty response body results in a zero value of T.
+func DecodeJSON[T any](resp *http.Response, errDo error) (T, error) {
+	var value T
+	err := decodeJSON(resp, errDo, &value)
+	return value, err
+}
 
-	return client.doBody(ctx, method, addr, "application/json", bytes.NewReader(body))
+func decodeJSON(resp *http.Response, errDo error, dst any) error {
+	return decodeBody(resp, errDo, dst, withCodec(JSONCodec{}), isSuccess)
 }
@@ -3,6 +3,7 @@ package httpclient_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"testing"
@@ -17,7 +18,7 @@ var methodsJSON = map[string]methodJSON{
 	MethodQuery:      (*httpclient.Client).QueryJSON,
 }
 
-type methodJSON = func(client *httpclient.Client, ctx context.Context, addr string, obj any) (*http.Response, error)
+type methodJSON = func(client *httpclient.Client, ctx context.Context, addr string, obj any, opts ...httpclient.RequestOption) (*http.Response, error)
 
 func TestClient_JSON(t *testing.T) {
 	type request struct {
@@ -64,3 +65,112 @@ func TestClient_JSON(t *testing.T) {
 		tc(method, call)
 	}
 }
+
+func TestDoJSON(t *testing.T) {
+	type request struct {
+		Foo string `json:"foo"`
+	}
+
+	type response struct {
+		Method string `json:"method"`
+		Foo    string `json:"foo"`
+	}
+
+	tc := func(method string) {
+		t.Run(method, func(t *testing.T) {
+			t.Parallel()
+
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				assertEqual(t, method, r.Method, "http method")
+
+				got := request{}
+				assertEqual(t, nil, json.NewDecoder(r.Body).Decode(&got), "request body")
+
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(response{Method: r.Method, Foo: got.Foo})
+			})
+			defer server.Assert(t)
+
+			client := httpclient.NewFrom(server.Client())
+			ctx := context.Background()
+
+			got, errCall := httpclient.DoJSON[request, response](ctx, client, method, server.URL, request{Foo: "bar"})
+
+			requireEqual(t, nil, errCall, "call error")
+			assertEqual(t, response{Method: method, Foo: "bar"}, got, "response")
+		})
+	}
+
+	for method := range methodsJSON {
+		tc(method)
+	}
+}
+
+func TestGetJSON(t *testing.T) {
+	t.Parallel()
+
+	type response struct {
+		Foo string `json:"foo"`
+	}
+
+	calls := map[string]func(ctx context.Context, client *httpclient.Client, addr string, opts ...httpclient.RequestOption) (response, error){
+		http.MethodGet:    httpclient.GetJSON[response],
+		http.MethodDelete: httpclient.DeleteJSON[response],
+	}
+
+	for method, call := range calls {
+		method, call := method, call
+		t.Run(method, func(t *testing.T) {
+			t.Parallel()
+
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				assertEqual(t, method, r.Method, "http method")
+				_, _ = io.WriteString(w, `{"foo": "bar"}`)
+			})
+			defer server.Assert(t)
+
+			client := httpclient.NewFrom(server.Client())
+
+			got, errCall := call(context.Background(), client, server.URL)
+
+			requireEqual(t, nil, errCall, "call error")
+			assertEqual(t, response{Foo: "bar"}, got, "response")
+		})
+	}
+}
+
+func TestGetJSON_StatusError(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"error": "not found"}`)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+
+	_, errCall := httpclient.GetJSON[map[string]string](context.Background(), client, server.URL)
+
+	var statusErr *httpclient.StatusError
+	requireEqual(t, true, errors.As(errCall, &statusErr), "expected status error, got %v", errCall)
+	assertEqual(t, http.StatusNotFound, statusErr.StatusCode, "status code")
+	assertEqual(t, http.MethodGet, statusErr.Method, "method")
+	assertEqual(t, server.URL, statusErr.URL, "url")
+}
+
+func TestGetJSON_EmptyBody(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+
+	got, errCall := httpclient.GetJSON[map[string]string](context.Background(), client, server.URL)
+
+	requireEqual(t, nil, errCall, "call error")
+	assertEqual(t, 0, len(got), "response")
+}
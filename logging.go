@@ -0,0 +1,320 @@
+package httpclient
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Redacted replaces the values of sensitive headers and parameters in logs.
+const Redacted = "REDACTED"
+
+// DefaultRedactHeaders are the headers which are always redacted by the Logging wrapper.
+var DefaultRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// DefaultRedactParams are the query and form parameters which are always redacted by the Logging wrapper.
+var DefaultRedactParams = []string{
+	"access_token", "refresh_token", "id_token", "client_secret", "password", "api_key", "apikey",
+	"X-Amz-Signature", "X-Amz-Credential", "X-Amz-Security-Token",
+}
+
+// LogOptions configures the Logging wrapper.
+type LogOptions struct {
+	// Logger receives the records. Default is slog.Default().
+	Logger *slog.Logger
+
+	// Level returns the level of the record for the request outcome.
+	// Default is DefaultLogLevel.
+	Level func(resp *http.Response, err error) slog.Level
+
+	// RedactHeaders are redacted in addition to DefaultRedactHeaders.
+	RedactHeaders []string
+
+	// RedactParams are query and form parameters redacted in addition to DefaultRedactParams.
+	RedactParams []string
+
+	// Headers enables logging of request and response headers.
+	Headers bool
+
+	// MaxBodySize enables logging of request and response bodies up to the given size.
+	// Only bodies with textual content types are logged.
+	// Request bodies are read using http.Request.GetBody, streaming bodies are not logged.
+	// Response bodies are captured as the caller reads them, so records of such responses
+	// are written when MaxBodySize bytes are read, the body ends or is closed.
+	MaxBodySize int
+}
+
+// DefaultLogLevel logs successful requests at the Info level, 4xx responses at the Warn level,
+// 5xx responses and transport errors at the Error level.
+func DefaultLogLevel(resp *http.Response, err error) slog.Level {
+	switch {
+	case err != nil || resp.StatusCode >= 500:
+		return slog.LevelError
+	case resp.StatusCode >= 400:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Logging creates a wrapper which logs requests using log/slog.
+//
+// Records contain the method, redacted URL, status, duration, request and response sizes
+// and, for requests executed by Retry, the attempt number.
+// Add the wrapper after Retry to log every attempt.
+func Logging(opts LogOptions) Wrapper {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if opts.Level == nil {
+		opts.Level = DefaultLogLevel
+	}
+
+	logger := &requestLogger{
+		opts:    opts,
+		headers: map[string]bool{},
+		params:  map[string]bool{},
+	}
+	for _, headers := range [][]string{DefaultRedactHeaders, opts.RedactHeaders} {
+		for _, header := range headers {
+			logger.headers[http.CanonicalHeaderKey(header)] = true
+		}
+	}
+	for _, params := range [][]string{DefaultRedactParams, opts.RedactParams} {
+		for _, param := range params {
+			logger.params[strings.ToLower(param)] = true
+		}
+	}
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return logger.do(next, req)
+		})
+	}
+}
+
+type requestLogger struct {
+	opts LogOptions
+	// headers and params are the sets of redacted names.
+	headers map[string]bool
+	params  map[string]bool
+}
+
+func (logger *requestLogger) do(next Doer, req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := next.Do(req)
+	duration := time.Since(start)
+
+	ctx := req.Context()
+	level := logger.opts.Level(resp, err)
+	if !logger.opts.Logger.Enabled(ctx, level) {
+		return resp, err
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", logger.redactURL(req.URL)),
+		slog.Duration("duration", duration),
+	}
+	if attempt := RetryAttempt(ctx); attempt > 0 {
+		attrs = append(attrs, slog.Int("attempt", attempt))
+	}
+	if req.ContentLength > 0 {
+		attrs = append(attrs, slog.Int64("request_size", req.ContentLength))
+	}
+	if logger.opts.Headers {
+		attrs = append(attrs, logger.headersAttr("request_headers", req.Header))
+	}
+	if body, ok := logger.requestBody(req); ok {
+		attrs = append(attrs, slog.String("request_body", body))
+	}
+
+	if err != nil {
+		attrs = append(attrs, slog.String("error", logger.redactError(err)))
+	} else {
+		attrs = append(attrs, slog.Int("status", resp.StatusCode))
+		if resp.ContentLength >= 0 {
+			attrs = append(attrs, slog.Int64("response_size", resp.ContentLength))
+		}
+		if logger.opts.Headers {
+			attrs = append(attrs, logger.headersAttr("response_headers", resp.Header))
+		}
+		if logger.captureResponseBody(resp) {
+			resp.Body = &loggedBody{
+				body:  resp.Body,
+				limit: logger.opts.MaxBodySize,
+				log: func(data []byte) {
+					body := logger.formatBody(resp.Header.Get("Content-Type"), data)
+					attrs := append(attrs, slog.String("response_body", body))
+					logger.opts.Logger.LogAttrs(ctx, level, "http request", attrs...)
+				},
+			}
+			return resp, nil
+		}
+	}
+
+	logger.opts.Logger.LogAttrs(ctx, level, "http request", attrs...)
+
+	return resp, err
+}
+
+func (logger *requestLogger) redactURL(u *url.URL) string {
+	redacted := *u
+	if _, hasPassword := u.User.Password(); hasPassword {
+		redacted.User = url.UserPassword(u.User.Username(), Redacted)
+	}
+	if u.RawQuery != "" {
+		redacted.RawQuery = logger.redactParams(u.RawQuery)
+	}
+	return redacted.String()
+}
+
+// redactError redacts the URL of *url.Error, which contains the full request URL.
+func (logger *requestLogger) redactError(err error) string {
+	message := err.Error()
+
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) || urlErr.URL == "" {
+		return message
+	}
+
+	redacted := Redacted
+	if u, errParse := url.Parse(urlErr.URL); errParse == nil {
+		redacted = logger.redactURL(u)
+	}
+	return strings.ReplaceAll(message, urlErr.URL, redacted)
+}
+
+// redactParams replaces the values of sensitive parameters in the url-encoded data.
+func (logger *requestLogger) redactParams(encoded string) string {
+	params, errParse := url.ParseQuery(encoded)
+	if errParse != nil {
+		return Redacted
+	}
+
+	for key, values := range params {
+		if logger.params[strings.ToLower(key)] {
+			for i := range values {
+				values[i] = Redacted
+			}
+		}
+	}
+	return params.Encode()
+}
+
+func (logger *requestLogger) headersAttr(name string, header http.Header) slog.Attr {
+	attrs := make([]any, 0, len(header))
+	for key, values := range header {
+		value := strings.Join(values, ", ")
+		if logger.headers[http.CanonicalHeaderKey(key)] {
+			value = Redacted
+		}
+		attrs = append(attrs, slog.String(key, value))
+	}
+	return slog.Group(name, attrs...)
+}
+
+func (logger *requestLogger) requestBody(req *http.Request) (string, bool) {
+	contentType := req.Header.Get("Content-Type")
+	if logger.opts.MaxBodySize <= 0 || req.GetBody == nil || !hasBody(req) || !isTextual(contentType) {
+		return "", false
+	}
+
+	body, errBody := req.GetBody()
+	if errBody != nil {
+		return "", false
+	}
+	defer body.Close()
+
+	data, errRead := io.ReadAll(io.LimitReader(body, int64(logger.opts.MaxBodySize)))
+	if errRead != nil {
+		return "", false
+	}
+
+	return logger.formatBody(contentType, data), true
+}
+
+func (logger *requestLogger) captureResponseBody(resp *http.Response) bool {
+	return logger.opts.MaxBodySize > 0 && resp.Body != nil && resp.Body != http.NoBody &&
+		isTextual(resp.Header.Get("Content-Type"))
+}
+
+func (logger *requestLogger) formatBody(contentType string, data []byte) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/x-www-form-urlencoded" {
+		return logger.redactParams(string(data))
+	}
+	return string(data)
+}
+
+// loggedBody captures the beginning of the response body as the caller reads it,
+// so streaming responses are returned without waiting for the body.
+// The record is written once the limit is reached, the body ends or is closed.
+type loggedBody struct {
+	body  io.ReadCloser
+	limit int
+	log   func(data []byte)
+
+	mu     sync.Mutex
+	data   []byte
+	logged bool
+}
+
+func (body *loggedBody) Read(p []byte) (int, error) {
+	n, err := body.body.Read(p)
+
+	body.mu.Lock()
+	defer body.mu.Unlock()
+
+	if !body.logged {
+		body.data = append(body.data, p[:min(n, body.limit-len(body.data))]...)
+		if len(body.data) >= body.limit || err != nil {
+			body.flush()
+		}
+	}
+	return n, err
+}
+
+func (body *loggedBody) Close() error {
+	body.mu.Lock()
+	if !body.logged {
+		body.flush()
+	}
+	body.mu.Unlock()
+
+	return body.body.Close()
+}
+
+func (body *loggedBody) flush() {
+	body.logged = true
+	body.log(body.data)
+}
+
+// isTextual reports whether the content type is a human-readable text format.
+func isTextual(contentType string) bool {
+	mediaType, _, errParse := mime.ParseMediaType(contentType)
+	if errParse != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+
+	switch mediaType {
+	case "application/json", "application/xml", "application/x-www-form-urlencoded",
+		"application/javascript", "application/x-ndjson":
+		return true
+	default:
+		return false
+	}
+}
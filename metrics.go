@@ -0,0 +1,181 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metrics creates metric instruments.
+// Label names are declared when an instrument is created,
+// label values are passed in the same order when it is updated.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	Counter(name, help string, labels ...string) Counter
+	Gauge(name, help string, labels ...string) Gauge
+	Histogram(name, help string, buckets []float64, labels ...string) Histogram
+}
+
+// Counter is a monotonically increasing value.
+type Counter interface {
+	Add(delta float64, labelValues ...string)
+}
+
+// Gauge is a value which can go up and down.
+type Gauge interface {
+	Add(delta float64, labelValues ...string)
+}
+
+// Histogram samples observations into buckets.
+type Histogram interface {
+	Observe(value float64, labelValues ...string)
+}
+
+// DefaultDurationBuckets are the request duration histogram buckets in seconds.
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// MetricsOptions configures the Instrument wrapper.
+type MetricsOptions struct {
+	Metrics Metrics
+
+	// Namespace prefixes the metric names. Default is "httpclient".
+	Namespace string
+
+	// DurationBuckets are the request duration histogram buckets in seconds.
+	// Default is DefaultDurationBuckets.
+	DurationBuckets []float64
+}
+
+// Instrument creates a wrapper which records request metrics:
+//
+//	<namespace>_requests_total{method, host, route, status}
+//	<namespace>_request_duration_seconds{method, host, route, status}
+//	<namespace>_requests_in_flight{method, host, route}
+//	<namespace>_retries_total{method, host, route}
+//	<namespace>_request_bytes_total{method, host, route}
+//	<namespace>_response_bytes_total{method, host, route}
+//
+// The status label is the status class, e.g. "2xx", or "error" for transport errors.
+// The duration is measured until the response headers are received.
+// The route label is set by WithRoute or by path templates, see PathParam.
+// Add the wrapper after Retry to record every attempt and the retries.
+func Instrument(opts MetricsOptions) Wrapper {
+	if opts.Namespace == "" {
+		opts.Namespace = "httpclient"
+	}
+	if len(opts.DurationBuckets) == 0 {
+		opts.DurationBuckets = DefaultDurationBuckets
+	}
+
+	name := func(metric string) string { return opts.Namespace + "_" + metric }
+	labels := []string{"method", "host", "route"}
+	statusLabels := []string{"method", "host", "route", "status"}
+
+	instruments := &requestMetrics{
+		requests:      opts.Metrics.Counter(name("requests_total"), "Total number of HTTP requests.", statusLabels...),
+		duration:      opts.Metrics.Histogram(name("request_duration_seconds"), "HTTP request duration until the response headers.", opts.DurationBuckets, statusLabels...),
+		inFlight:      opts.Metrics.Gauge(name("requests_in_flight"), "Number of HTTP requests waiting for response headers.", labels...),
+		retries:       opts.Metrics.Counter(name("retries_total"), "Total number of retried HTTP requests.", labels...),
+		requestBytes:  opts.Metrics.Counter(name("request_bytes_total"), "Total size of HTTP request bodies.", labels...),
+		responseBytes: opts.Metrics.Counter(name("response_bytes_total"), "Total size of read HTTP response bodies.", labels...),
+	}
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return instruments.do(next, req)
+		})
+	}
+}
+
+type requestMetrics struct {
+	requests      Counter
+	duration      Histogram
+	inFlight      Gauge
+	retries       Counter
+	requestBytes  Counter
+	responseBytes Counter
+}
+
+func (metrics *requestMetrics) do(next Doer, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	labels := []string{req.Method, req.URL.Host, Route(ctx)}
+
+	if RetryAttempt(ctx) > 1 {
+		metrics.retries.Add(1, labels...)
+	}
+
+	if hasBody(req) {
+		req = req.Clone(ctx)
+		req.Body = &countingBody{body: req.Body, counter: metrics.requestBytes, labels: labels}
+	}
+
+	metrics.inFlight.Add(1, labels...)
+	start := time.Now()
+	resp, err := next.Do(req)
+	duration := time.Since(start)
+	metrics.inFlight.Add(-1, labels...)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode/100) + "xx"
+		if resp.Body != nil && resp.Body != http.NoBody {
+			resp.Body = &countingBody{body: resp.Body, counter: metrics.responseBytes, labels: labels}
+		}
+	}
+
+	statusLabels := append(labels[:len(labels):len(labels)], status)
+	metrics.requests.Add(1, statusLabels...)
+	metrics.duration.Observe(duration.Seconds(), statusLabels...)
+
+	return resp, err
+}
+
+// countingBody adds the number of read bytes to the counter.
+type countingBody struct {
+	body    io.ReadCloser
+	counter Counter
+	labels  []string
+}
+
+func (body *countingBody) Read(p []byte) (int, error) {
+	n, err := body.body.Read(p)
+	if n > 0 {
+		body.counter.Add(float64(n), body.labels...)
+	}
+	return n, err
+}
+
+func (body *countingBody) Close() error {
+	return body.body.Close()
+}
+
+type routeKey struct{}
+
+// WithRoute sets the route template of requests made with the context, e.g. "/users/{id}".
+// It is used as a low-cardinality label by the Instrument wrapper.
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey{}, route)
+}
+
+// Route returns the route template of the request context.
+func Route(ctx context.Context) string {
+	route, _ := ctx.Value(routeKey{}).(string)
+	return route
+}
+
+// routeTemplate returns the path template of the address, without the scheme, host, query and fragment.
+func routeTemplate(addr string) string {
+	if end := strings.IndexAny(addr, "?#"); end >= 0 {
+		addr = addr[:end]
+	}
+	if _, rest, ok := strings.Cut(addr, "://"); ok {
+		addr = "/"
+		if start := strings.IndexByte(rest, '/'); start >= 0 {
+			addr = rest[start:]
+		}
+	}
+	return addr
+}
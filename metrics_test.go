@@ -0,0 +1,226 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ninedraft/httpclient"
+)
+
+// fakeMetrics records the sums and observation counts of instruments by name and label values.
+type fakeMetrics struct {
+	mu     sync.Mutex
+	values map[string]float64
+	counts map[string]int
+	labels map[string][]string
+}
+
+func newFakeMetrics() *fakeMetrics {
+	return &fakeMetrics{
+		values: map[string]float64{},
+		counts: map[string]int{},
+		labels: map[string][]string{},
+	}
+}
+
+type fakeInstrument struct {
+	metrics *fakeMetrics
+	name    string
+}
+
+func (metrics *fakeMetrics) instrument(name string, labels []string) *fakeInstrument {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	metrics.labels[name] = labels
+	return &fakeInstrument{metrics: metrics, name: name}
+}
+
+func (metrics *fakeMetrics) Counter(name, _ string, labels ...string) httpclient.Counter {
+	return metrics.instrument(name, labels)
+}
+
+func (metrics *fakeMetrics) Gauge(name, _ string, labels ...string) httpclient.Gauge {
+	return metrics.instrument(name, labels)
+}
+
+func (metrics *fakeMetrics) Histogram(name, _ string, _ []float64, labels ...string) httpclient.Histogram {
+	return metrics.instrument(name, labels)
+}
+
+func (instrument *fakeInstrument) Add(delta float64, labelValues ...string) {
+	instrument.Observe(delta, labelValues...)
+}
+
+func (instrument *fakeInstrument) Observe(value float64, labelValues ...string) {
+	metrics := instrument.metrics
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	if len(labelValues) != len(metrics.labels[instrument.name]) {
+		panic("label values do not match label names of " + instrument.name)
+	}
+	key := instrument.name + "{" + strings.Join(labelValues, ",") + "}"
+	metrics.values[key] += value
+	metrics.counts[key]++
+}
+
+func (metrics *fakeMetrics) value(key string) float64 {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	return metrics.values[key]
+}
+
+func (metrics *fakeMetrics) count(key string) int {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	return metrics.counts[key]
+}
+
+func TestInstrument(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		if r.URL.Path == "/users/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = io.WriteString(w, "hello")
+	})
+	defer server.Assert(t)
+
+	metrics := newFakeMetrics()
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Instrument(httpclient.MetricsOptions{Metrics: metrics}))
+
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	for _, id := range []string{"1", "2", "missing"} {
+		resp, err := client.Post(context.Background(), server.URL+"/users/{id}?verbose=1", "text/plain",
+			strings.NewReader("body"), httpclient.PathParam("id", id))
+		requireEqual(t, nil, err, "call error")
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	labels := "POST," + host + ",/users/{id}"
+	assertEqual(t, 2, metrics.value("httpclient_requests_total{"+labels+",2xx}"), "2xx requests")
+	assertEqual(t, 1, metrics.value("httpclient_requests_total{"+labels+",4xx}"), "4xx requests")
+	assertEqual(t, 2, metrics.count("httpclient_request_duration_seconds{"+labels+",2xx}"), "2xx durations")
+	assertEqual(t, 12, metrics.value("httpclient_request_bytes_total{"+labels+"}"), "request bytes")
+	assertEqual(t, 10, metrics.value("httpclient_response_bytes_total{"+labels+"}"), "response bytes")
+	assertEqual(t, 0, metrics.value("httpclient_requests_in_flight{"+labels+"}"), "in-flight requests")
+	assertEqual(t, 6, metrics.count("httpclient_requests_in_flight{"+labels+"}"), "in-flight updates")
+	assertEqual(t, 0, metrics.value("httpclient_retries_total{"+labels+"}"), "retries")
+}
+
+func TestInstrument_Route(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	defer server.Assert(t)
+
+	metrics := newFakeMetrics()
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Instrument(httpclient.MetricsOptions{Metrics: metrics, Namespace: "api"}))
+
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	ctx := httpclient.WithRoute(context.Background(), "/items/{id}")
+	resp, err := client.Get(ctx, server.URL+"/items/42")
+	requireEqual(t, nil, err, "call error")
+	resp.Body.Close()
+
+	resp, err = client.Get(context.Background(), server.URL+"/items/42")
+	requireEqual(t, nil, err, "call error")
+	resp.Body.Close()
+
+	assertEqual(t, 1, metrics.value("api_requests_total{GET,"+host+",/items/{id},2xx}"), "route from context")
+	assertEqual(t, 1, metrics.value("api_requests_total{GET,"+host+",,2xx}"), "no route")
+}
+
+func TestInstrument_Errors(t *testing.T) {
+	t.Parallel()
+
+	errTransport := errors.New("transport error")
+	metrics := newFakeMetrics()
+	client := httpclient.NewFrom(httpclient.DoerFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errTransport
+	}))
+	client.Use(httpclient.Instrument(httpclient.MetricsOptions{Metrics: metrics}))
+
+	_, err := client.Get(context.Background(), "http://example.com/")
+	assertEqual(t, true, errors.Is(err, errTransport), "unexpected error: %v", err)
+
+	assertEqual(t, 1, metrics.value("httpclient_requests_total{GET,example.com,,error}"), "failed requests")
+	assertEqual(t, 0, metrics.value("httpclient_requests_in_flight{GET,example.com,}"), "in-flight requests")
+}
+
+func TestInstrument_Retries(t *testing.T) {
+	t.Parallel()
+
+	server, _ := flakyServer(t, 2, http.StatusServiceUnavailable, nil)
+	defer server.Assert(t)
+
+	metrics := newFakeMetrics()
+	client := httpclient.NewFrom(server.Client())
+	client.Use(
+		httpclient.Retry(httpclient.RetryOptions{BaseDelay: time.Millisecond}),
+		httpclient.Instrument(httpclient.MetricsOptions{Metrics: metrics}),
+	)
+
+	resp, err := client.Get(context.Background(), server.URL)
+	requireEqual(t, nil, err, "call error")
+	resp.Body.Close()
+
+	labels := "GET," + strings.TrimPrefix(server.URL, "http://") + ","
+	assertEqual(t, 2, metrics.value("httpclient_retries_total{"+labels+"}"), "retries")
+	assertEqual(t, 2, metrics.value("httpclient_requests_total{"+labels+",5xx}"), "5xx requests")
+	assertEqual(t, 1, metrics.value("httpclient_requests_total{"+labels+",2xx}"), "2xx requests")
+}
+
+func TestInstrument_InFlight(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+	defer server.Assert(t)
+
+	metrics := newFakeMetrics()
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Instrument(httpclient.MetricsOptions{Metrics: metrics}))
+
+	key := "httpclient_requests_in_flight{GET," + strings.TrimPrefix(server.URL, "http://") + ",}"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(context.Background(), server.URL)
+			if err != nil {
+				t.Errorf("call error: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for metrics.value(key) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assertEqual(t, 3, metrics.value(key), "in-flight requests")
+
+	close(release)
+	wg.Wait()
+	assertEqual(t, 0, metrics.value(key), "in-flight requests after responses")
+}
@@ -0,0 +1,54 @@
+package httpclient
+
+import "net/http"
+
+// DoerFunc is an adapter to allow the use of ordinary functions as Doer.
+type DoerFunc func(req *http.Request) (*http.Response, error)
+
+// Do calls fn(req).
+func (fn DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+// Wrapper is a middleware which wraps a Doer.
+// Unlike Client.Middleware, it can observe and replace responses and errors.
+type Wrapper func(next Doer) Doer
+
+// Wrap applies the wrappers to the doer.
+// The first wrapper is the outermost one: it sees the request first and the response last.
+func Wrap(doer Doer, wrappers ...Wrapper) Doer {
+	for i := len(wrappers) - 1; i >= 0; i-- {
+		doer = wrappers[i](doer)
+	}
+	return doer
+}
+
+// RequestMiddleware creates a Wrapper from a function that only modifies requests.
+// A nil function results in a no-op wrapper.
+func RequestMiddleware(fn func(req *http.Request) (*http.Request, error)) Wrapper {
+	return func(next Doer) Doer {
+		if fn == nil {
+			return next
+		}
+
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			req, err := fn(req)
+			if err != nil {
+				return nil, err
+			}
+			return next.Do(req)
+		})
+	}
+}
+
+// Use appends the wrappers to the client chain.
+func (client *Client) Use(wrappers ...Wrapper) {
+	client.Chain = append(client.Chain, wrappers...)
+}
+
+// doer builds the request execution pipeline:
+// the Middleware function goes first, then the Chain wrappers, then the Doer.
+func (client *Client) doer() Doer {
+	doer := Wrap(client.Doer, client.Chain...)
+	return RequestMiddleware(client.Middleware)(doer)
+}
@@ -0,0 +1,107 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ninedraft/httpclient"
+)
+
+func TestClient_Use(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	var mu sync.Mutex
+	var calls []string
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+
+	stage := func(name string) httpclient.Wrapper {
+		return func(next httpclient.Doer) httpclient.Doer {
+			return httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+				record(name + " request")
+				resp, err := next.Do(req)
+				record(name + " response " + resp.Status)
+				return resp, err
+			})
+		}
+	}
+
+	client := httpclient.NewFrom(server.Client())
+	client.Middleware = func(req *http.Request) (*http.Request, error) {
+		record("middleware " + req.Header.Get("Content-Type"))
+		return req, nil
+	}
+	client.Use(stage("first"), stage("second"))
+	client.Use(stage("third"))
+
+	resp, errCall := client.Post(context.Background(), server.URL, "text/plain", strings.NewReader("hello"))
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+
+	assertEqualSlices(t, []string{
+		"middleware text/plain",
+		"first request",
+		"second request",
+		"third request",
+		"third response 200 OK",
+		"second response 200 OK",
+		"first response 200 OK",
+	}, calls, "calls")
+}
+
+func TestClient_MiddlewareError(t *testing.T) {
+	t.Parallel()
+
+	errMiddleware := errors.New("middleware error")
+
+	client := httpclient.NewFrom(httpclient.DoerFunc(func(*http.Request) (*http.Response, error) {
+		t.Error("doer must not be called")
+		return nil, nil
+	}))
+	client.Middleware = func(*http.Request) (*http.Request, error) {
+		return nil, errMiddleware
+	}
+
+	_, errCall := client.Get(context.Background(), "http://example.com")
+
+	assertEqual(t, errMiddleware, errCall, "call error")
+}
+
+func TestClient_WrapperResponse(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(func(next httpclient.Doer) httpclient.Doer {
+		return httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.Do(req)
+			if err == nil && resp.StatusCode == http.StatusTeapot {
+				resp.StatusCode = http.StatusOK
+			}
+			return resp, err
+		})
+	})
+
+	resp, errCall := client.Get(context.Background(), server.URL)
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+	assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+}
@@ -2,6 +2,7 @@ package httpclient
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -78,28 +79,41 @@ func MultipartFields(fields url.Values) WriteMultipart {
 }
 
 // PostMultipart sends a POST request with multipart data.
-func (client *Client) PostMultipart(ctx context.Context, addr string, writeMultipart WriteMultipart) (*http.Response, error) {
-	return client.doMultipart(ctx, http.MethodPost, addr, writeMultipart)
+func (client *Client) PostMultipart(ctx context.Context, addr string, writeMultipart WriteMultipart, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(http.MethodPost, addr).Multipart(writeMultipart).Apply(opts...).Do(ctx)
 }
 
 // PutMultipart sends a PUT request with multipart data.
-func (client *Client) PutMultipart(ctx context.Context, addr string, writeMultipart WriteMultipart) (*http.Response, error) {
-	return client.doMultipart(ctx, http.MethodPut, addr, writeMultipart)
+func (client *Client) PutMultipart(ctx context.Context, addr string, writeMultipart WriteMultipart, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(http.MethodPut, addr).Multipart(writeMultipart).Apply(opts...).Do(ctx)
 }
 
 // PatchMultipart sends a PATCH request with multipart data.
-func (client *Client) PatchMultipart(ctx context.Context, addr string, writeMultipart WriteMultipart) (*http.Response, error) {
-	return client.doMultipart(ctx, http.MethodPatch, addr, writeMultipart)
+func (client *Client) PatchMultipart(ctx context.Context, addr string, writeMultipart WriteMultipart, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(http.MethodPatch, addr).Multipart(writeMultipart).Apply(opts...).Do(ctx)
 }
 
-func (client *Client) QueryMultipart(ctx context.Context, addr string, writeMultipart WriteMultipart) (*http.Response, error) {
-	return client.doMultipart(ctx, methodQuery, addr, writeMultipart)
+// QueryMultipart sends a QUERY request with multipart data.
+func (client *Client) QueryMultipart(ctx context.Context, addr string, writeMultipart WriteMultipart, opts ...RequestOption) (*http.Response, error) {
+	return client.NewRequest(methodQuery, addr).Multipart(writeMultipart).Apply(opts...).Do(ctx)
 }
 
-func (client *Client) doMultipart(ctx context.Context, method, addr string, writeMultipart WriteMultipart) (*http.Response, error) {
+func (client *Client) doMultipart(ctx context.Context, method, addr string, writeMultipart WriteMultipart, options *requestOptions) (*http.Response, error) {
+	if client.MultipartMaxMemory > 0 {
+		return client.doMultipartBuffered(ctx, method, addr, writeMultipart, options)
+	}
+
+	return client.doMultipartStream(ctx, method, addr, writeMultipart, options)
+}
+
+// doMultipartStream streams the multipart body through a pipe.
+// The writer goroutine always terminates: if the request fails before the body is consumed,
+// the pipe is closed with the request error. Errors of the request and of the writer are joined,
+// unless the writer failed because of the closed pipe.
+func (client *Client) doMultipartStream(ctx context.Context, method, addr string, writeMultipart WriteMultipart, options *requestOptions) (*http.Response, error) {
 	body, writer := io.Pipe()
 
-	req, errReq := client.newRequest(ctx, method, addr, body)
+	req, errReq := client.newRequest(ctx, method, addr, body, options)
 	if errReq != nil {
 		return nil, errReq
 	}
@@ -110,16 +124,56 @@ func (client *Client) doMultipart(ctx context.Context, method, addr string, writ
 	done := make(chan error, 1)
 	go func() {
 		defer close(done)
-		defer writer.Close()
-		defer mwr.Close()
 
-		done <- writeMultipart(mwr)
+		errWrite := writeMultipart(mwr)
+		if errWrite == nil {
+			errWrite = mwr.Close()
+		}
+		_ = writer.CloseWithError(errWrite)
+
+		done <- errWrite
 	}()
 
-	resp, errDo := client.Doer.Do(req)
+	resp, errDo := client.do(req, options)
 	if errDo != nil {
-		return resp, errDo
+		_ = body.CloseWithError(errDo)
+
+		errWrite := <-done
+		if errWrite == nil || errors.Is(errWrite, errDo) {
+			return resp, errDo
+		}
+		return resp, errors.Join(errDo, errWrite)
 	}
 
 	return resp, <-done
 }
+
+func (client *Client) doMultipartBuffered(ctx context.Context, method, addr string, writeMultipart WriteMultipart, options *requestOptions) (*http.Response, error) {
+	body := newSpool(client.MultipartMaxMemory)
+	defer body.Close()
+
+	mwr := multipart.NewWriter(body)
+	if err := writeMultipart(mwr); err != nil {
+		return nil, err
+	}
+	if err := mwr.Close(); err != nil {
+		return nil, err
+	}
+
+	req, errReq := client.newRequest(ctx, method, addr, nil, options)
+	if errReq != nil {
+		return nil, errReq
+	}
+	req.Header.Set(headerContentType, mwr.FormDataContentType())
+
+	req.ContentLength = body.Len()
+	req.GetBody = body.Open
+
+	reqBody, errOpen := body.Open()
+	if errOpen != nil {
+		return nil, errOpen
+	}
+	req.Body = reqBody
+
+	return client.do(req, options)
+}
@@ -2,10 +2,15 @@ package httpclient_test
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ninedraft/httpclient"
 )
@@ -17,7 +22,7 @@ var methodsMultiPart = map[string]methodMultipart{
 	MethodQuery:      (*httpclient.Client).QueryMultipart,
 }
 
-type methodMultipart = func(client *httpclient.Client, ctx context.Context, addr string, writeMultipart httpclient.WriteMultipart) (*http.Response, error)
+type methodMultipart = func(client *httpclient.Client, ctx context.Context, addr string, writeMultipart httpclient.WriteMultipart, opts ...httpclient.RequestOption) (*http.Response, error)
 
 func TestClient_MultipartFile(t *testing.T) {
 	const field, filename, value = "field", "filename", "value"
@@ -144,3 +149,153 @@ func TestClient_WriteMultiparts(t *testing.T) {
 		tc(method, call)
 	}
 }
+
+func TestClient_MultipartBuffered(t *testing.T) {
+	const field, filename = "field", "filename"
+	value := strings.Repeat("value", 100)
+
+	tc := func(name string, maxMemory int64) {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("TMPDIR", t.TempDir())
+
+			for method, call := range methodsMultiPart {
+				server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Path != "/final" {
+						_, _ = io.Copy(io.Discard, r.Body)
+						http.Redirect(w, r, "/final", http.StatusTemporaryRedirect)
+						return
+					}
+
+					assertEqual(t, method, r.Method, "http method")
+					assertNotEqual(t, -1, r.ContentLength, "content length")
+
+					errParse := r.ParseMultipartForm(1 << 20)
+					requireEqual(t, nil, errParse, "parse multipart form error")
+
+					file, errFile := r.MultipartForm.File[field][0].Open()
+					requireEqual(t, nil, errFile, "file open error")
+					defer file.Close()
+
+					assertEqual(t, value, readString(t, file), "field value")
+
+					w.WriteHeader(http.StatusOK)
+				})
+
+				client := httpclient.NewFrom(server.Client())
+				client.MultipartMaxMemory = maxMemory
+
+				resp, errCall := call(client, context.Background(), server.URL,
+					httpclient.MultipartFile(field, filename, strings.NewReader(value)))
+
+				requireEqual(t, nil, errCall, "%s: call error", method)
+				resp.Body.Close()
+
+				assertEqual(t, http.StatusOK, resp.StatusCode, "%s: status code", method)
+				server.Assert(t)
+			}
+
+			spooled, _ := filepath.Glob(filepath.Join(os.TempDir(), "httpclient-spool-*"))
+			assertEqual(t, 0, len(spooled), "temporary files must be removed: %v", spooled)
+		})
+	}
+
+	tc("memory", 1<<20)
+	tc("file", 16)
+}
+
+func TestClient_MultipartBufferedRetry(t *testing.T) {
+	t.Parallel()
+
+	var values = url.Values{
+		"field": {"value"},
+	}
+
+	calls := 0
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		errParse := r.ParseMultipartForm(1 << 20)
+		requireEqual(t, nil, errParse, "parse multipart form error")
+		assertEqualSlices(t, values["field"], r.MultipartForm.Value["field"], "field value")
+
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.MultipartMaxMemory = 1 << 10
+	client.Use(httpclient.Retry(fastRetry))
+
+	resp, errCall := client.PutMultipart(context.Background(), server.URL, httpclient.MultipartFields(values))
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+
+	assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+	assertEqual(t, 2, calls, "calls")
+}
+
+func TestClient_MultipartDoError(t *testing.T) {
+	errTransport := errors.New("transport error")
+	errWrite := errors.New("write error")
+
+	client := httpclient.NewFrom(httpclient.DoerFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errTransport
+	}))
+
+	for method, call := range methodsMultiPart {
+		_, errCall := call(client, context.Background(), "http://example.com",
+			func(w httpclient.MultipartWriter) error {
+				// blocks until the pipe is closed
+				_ = w.WriteField("field", "value")
+				return errWrite
+			})
+
+		assertEqual(t, true, errors.Is(errCall, errTransport), "%s: transport error: %v", method, errCall)
+		assertEqual(t, true, errors.Is(errCall, errWrite), "%s: write error: %v", method, errCall)
+	}
+
+	leaked := leakedGoroutines(time.Second)
+	assertEqual(t, "", leaked, "leaked goroutines")
+}
+
+func TestClient_MultipartBufferedRejected(t *testing.T) {
+	errRejected := errors.New("rejected")
+
+	client := httpclient.New()
+	client.MultipartMaxMemory = 16
+	client.Use(func(httpclient.Doer) httpclient.Doer {
+		return httpclient.DoerFunc(func(*http.Request) (*http.Response, error) {
+			return nil, errRejected
+		})
+	})
+
+	_, errCall := client.PostMultipart(context.Background(), "http://example.com",
+		httpclient.MultipartFile("file", "file.txt", strings.NewReader(strings.Repeat("x", 1<<10))))
+
+	assertEqual(t, true, errors.Is(errCall, errRejected), "expected rejection, got %v", errCall)
+
+	spooled, _ := filepath.Glob(filepath.Join(os.TempDir(), "httpclient-spool-*"))
+	assertEqual(t, 0, len(spooled), "temporary files must be removed: %v", spooled)
+}
+
+func TestClient_MultipartFieldsDoError(t *testing.T) {
+	errTransport := errors.New("transport error")
+
+	client := httpclient.NewFrom(httpclient.DoerFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errTransport
+	}))
+
+	_, errCall := client.PostMultipart(context.Background(), "http://example.com",
+		httpclient.MultipartFields(url.Values{"field": {"value"}}))
+
+	assertEqual(t, true, errors.Is(errCall, errTransport), "transport error: %v", errCall)
+	// the write error wraps the transport error, so it is reported once
+	assertEqual(t, 1, strings.Count(errCall.Error(), errTransport.Error()), "error message: %v", errCall)
+
+	leaked := leakedGoroutines(time.Second)
+	assertEqual(t, "", leaked, "leaked goroutines")
+}
@@ -0,0 +1,239 @@
+// Package oauth2 implements the client side of OAuth 2.0 client credentials
+// and refresh token grants, as defined by RFC 6749.
+//
+// Token sources created by this package can be used with httpclient.BearerAuth
+// or attached to a client with Client.Middleware:
+//
+//	source := cfg.ClientCredentials()
+//	client.Middleware = source.Middleware()
+package oauth2
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ninedraft/httpclient"
+)
+
+// AuthStyle defines how the client authenticates at the token endpoint.
+type AuthStyle int
+
+const (
+	// AuthStyleHeader sends the client credentials using HTTP Basic authentication.
+	AuthStyleHeader AuthStyle = iota
+	// AuthStyleBody sends the client credentials as client_id and client_secret form parameters.
+	AuthStyleBody
+)
+
+// Config describes an OAuth 2.0 client.
+type Config struct {
+	ClientID     string
+	ClientSecret string
+
+	// TokenURL is the address of the token endpoint.
+	TokenURL string
+
+	// Scopes are the requested scopes of the access token.
+	Scopes []string
+
+	// Audience is the target service of the access token.
+	// It is sent as the "audience" parameter, which is supported by many providers.
+	Audience string
+
+	// AuthStyle is the client authentication method. Default is AuthStyleHeader.
+	AuthStyle AuthStyle
+
+	// EndpointParams are additional parameters of every token request.
+	EndpointParams url.Values
+
+	// Client is used to call the token endpoint. Default is httpclient.New().
+	// It must not use the token sources of this config.
+	Client *httpclient.Client
+}
+
+// ClientCredentials returns a token source using the client credentials grant.
+func (cfg *Config) ClientCredentials() *TokenSource {
+	return newTokenSource(cfg, func(*TokenSource) url.Values {
+		return url.Values{"grant_type": {"client_credentials"}}
+	})
+}
+
+// RefreshToken returns a token source using the refresh token grant.
+// If the authorization server issues a new refresh token, it replaces the old one.
+func (cfg *Config) RefreshToken(refreshToken string) *TokenSource {
+	source := newTokenSource(cfg, func(source *TokenSource) url.Values {
+		return url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {source.RefreshToken()},
+		}
+	})
+	source.refreshToken = refreshToken
+
+	return source
+}
+
+// TokenSource fetches access tokens from the token endpoint and caches them until they are close to expiry.
+// It implements httpclient.TokenSource.
+type TokenSource struct {
+	cfg    *Config
+	client *httpclient.Client
+	grant  func(source *TokenSource) url.Values
+	cache  *httpclient.CachedTokenSource
+
+	mu           sync.Mutex
+	refreshToken string
+}
+
+var _ httpclient.TokenSource = (*TokenSource)(nil)
+
+func newTokenSource(cfg *Config, grant func(source *TokenSource) url.Values) *TokenSource {
+	source := &TokenSource{cfg: cfg, client: cfg.client(), grant: grant}
+	source.cache = httpclient.NewCachedTokenSource(httpclient.TokenFunc(source.fetch), 0)
+	return source
+}
+
+// Token returns a cached token or fetches a new one.
+func (source *TokenSource) Token(ctx context.Context) (httpclient.Token, error) {
+	return source.cache.Token(ctx)
+}
+
+// RefreshToken returns the current refresh token.
+func (source *TokenSource) RefreshToken() string {
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	return source.refreshToken
+}
+
+// Middleware returns a function for Client.Middleware,
+// which sets the Authorization header of outgoing requests.
+func (source *TokenSource) Middleware() func(req *http.Request) (*http.Request, error) {
+	return func(req *http.Request) (*http.Request, error) {
+		token, errToken := source.Token(req.Context())
+		if errToken != nil {
+			return nil, errToken
+		}
+
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", token.AuthorizationHeader())
+
+		return req, nil
+	}
+}
+
+type tokenResponse struct {
+	AccessToken  string      `json:"access_token"`
+	TokenType    string      `json:"token_type"`
+	ExpiresIn    json.Number `json:"expires_in"`
+	RefreshToken string      `json:"refresh_token"`
+}
+
+func (source *TokenSource) fetch(ctx context.Context) (httpclient.Token, error) {
+	cfg := source.cfg
+
+	form := source.grant(source)
+	if len(cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(cfg.Scopes, " "))
+	}
+	if cfg.Audience != "" {
+		form.Set("audience", cfg.Audience)
+	}
+	for key, values := range cfg.EndpointParams {
+		form[key] = append([]string(nil), values...)
+	}
+
+	var opts []httpclient.RequestOption
+	switch cfg.AuthStyle {
+	case AuthStyleBody:
+		form.Set("client_id", cfg.ClientID)
+		if cfg.ClientSecret != "" {
+			form.Set("client_secret", cfg.ClientSecret)
+		}
+	default:
+		opts = append(opts, httpclient.WithHeader("Authorization", basicAuth(cfg.ClientID, cfg.ClientSecret)))
+	}
+	opts = append(opts, httpclient.WithHeader("Accept", "application/json"))
+
+	now := time.Now()
+	resp, errFetch := httpclient.DecodeJSON[tokenResponse](source.client.PostForm(ctx, cfg.TokenURL, form, opts...))
+	if errFetch != nil {
+		return httpclient.Token{}, tokenError(errFetch)
+	}
+	if resp.AccessToken == "" {
+		return httpclient.Token{}, errors.New("oauth2: server response is missing access_token")
+	}
+
+	token := httpclient.Token{
+		AccessToken: resp.AccessToken,
+		TokenType:   resp.TokenType,
+	}
+	if expiresIn, err := resp.ExpiresIn.Int64(); err == nil && expiresIn > 0 {
+		token.Expiry = now.Add(time.Duration(expiresIn) * time.Second)
+	}
+
+	if resp.RefreshToken != "" {
+		source.mu.Lock()
+		source.refreshToken = resp.RefreshToken
+		source.mu.Unlock()
+	}
+
+	return token, nil
+}
+
+func (cfg *Config) client() *httpclient.Client {
+	if cfg.Client != nil {
+		return cfg.Client
+	}
+	return httpclient.New()
+}
+
+// basicAuth encodes the client credentials as described in RFC 6749, section 2.3.1.
+func basicAuth(clientID, clientSecret string) string {
+	credentials := url.QueryEscape(clientID) + ":" + url.QueryEscape(clientSecret)
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+}
+
+// Error is an error response of the token endpoint, as defined by RFC 6749, section 5.2.
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	URI         string `json:"error_uri"`
+
+	// Response is the status error of the token endpoint response.
+	Response *httpclient.StatusError `json:"-"`
+}
+
+func (err *Error) Error() string {
+	msg := "oauth2: " + err.Code
+	if err.Description != "" {
+		msg += ": " + err.Description
+	}
+	return msg
+}
+
+// Unwrap returns the status error of the response.
+func (err *Error) Unwrap() error {
+	return err.Response
+}
+
+func tokenError(errFetch error) error {
+	var statusErr *httpclient.StatusError
+	if !errors.As(errFetch, &statusErr) {
+		return fmt.Errorf("oauth2: fetch token: %w", errFetch)
+	}
+
+	errResp := &Error{}
+	if json.Unmarshal(statusErr.Body, errResp) != nil || errResp.Code == "" {
+		return fmt.Errorf("oauth2: fetch token: %w", errFetch)
+	}
+	errResp.Response = statusErr
+
+	return errResp
+}
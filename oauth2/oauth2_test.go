@@ -0,0 +1,257 @@
+package oauth2_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ninedraft/httpclient"
+	"github.com/ninedraft/httpclient/oauth2"
+)
+
+type tokenEndpoint struct {
+	t     *testing.T
+	calls atomic.Int32
+	// check validates the token request form.
+	check func(r *http.Request)
+	// response is encoded as JSON response body.
+	response func(n int32) (int, any)
+}
+
+func (endpoint *tokenEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := endpoint.calls.Add(1)
+
+	if err := r.ParseForm(); err != nil {
+		endpoint.t.Errorf("parse form: %v", err)
+	}
+	if endpoint.check != nil {
+		endpoint.check(r)
+	}
+
+	status, body := endpoint.response(n)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func startEndpoint(t *testing.T, endpoint *tokenEndpoint) *httptest.Server {
+	endpoint.t = t
+	server := httptest.NewServer(endpoint)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func assertEqual[E comparable](t *testing.T, expected, actual E, msg string) {
+	t.Helper()
+
+	if expected != actual {
+		t.Errorf("%s: expected %v, got %v", msg, expected, actual)
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	t.Parallel()
+
+	endpoint := &tokenEndpoint{
+		check: func(r *http.Request) {
+			id, secret, ok := r.BasicAuth()
+			assertEqual(t, true, ok, "basic auth")
+			assertEqual(t, "client id", must(url.QueryUnescape(id)), "client id")
+			assertEqual(t, "secret/value", must(url.QueryUnescape(secret)), "client secret")
+			assertEqual(t, "client_credentials", r.PostForm.Get("grant_type"), "grant type")
+			assertEqual(t, "read write", r.PostForm.Get("scope"), "scope")
+			assertEqual(t, "https://api.example.com", r.PostForm.Get("audience"), "audience")
+			assertEqual(t, "", r.PostForm.Get("client_secret"), "client secret in body")
+		},
+		response: func(int32) (int, any) {
+			return http.StatusOK, map[string]any{
+				"access_token": "access",
+				"token_type":   "bearer",
+				"expires_in":   3600,
+			}
+		},
+	}
+	tokenServer := startEndpoint(t, endpoint)
+
+	cfg := &oauth2.Config{
+		ClientID:     "client id",
+		ClientSecret: "secret/value",
+		TokenURL:     tokenServer.URL,
+		Scopes:       []string{"read", "write"},
+		Audience:     "https://api.example.com",
+		Client:       httpclient.NewFrom(tokenServer.Client()),
+	}
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, "Bearer access", r.Header.Get("Authorization"), "authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(api.Close)
+
+	client := httpclient.NewFrom(api.Client())
+	client.Middleware = cfg.ClientCredentials().Middleware()
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(context.Background(), api.URL)
+		if err != nil {
+			t.Fatalf("call error: %v", err)
+		}
+		resp.Body.Close()
+		assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+	}
+
+	assertEqual(t, 1, endpoint.calls.Load(), "token requests")
+}
+
+func TestClientCredentials_AuthStyleBody(t *testing.T) {
+	t.Parallel()
+
+	endpoint := &tokenEndpoint{
+		check: func(r *http.Request) {
+			_, _, ok := r.BasicAuth()
+			assertEqual(t, false, ok, "basic auth")
+			assertEqual(t, "id", r.PostForm.Get("client_id"), "client id")
+			assertEqual(t, "secret", r.PostForm.Get("client_secret"), "client secret")
+			assertEqual(t, "value", r.PostForm.Get("extra"), "endpoint param")
+		},
+		response: func(int32) (int, any) {
+			return http.StatusOK, map[string]any{"access_token": "access", "token_type": "Bearer"}
+		},
+	}
+	tokenServer := startEndpoint(t, endpoint)
+
+	cfg := &oauth2.Config{
+		ClientID:       "id",
+		ClientSecret:   "secret",
+		TokenURL:       tokenServer.URL,
+		AuthStyle:      oauth2.AuthStyleBody,
+		EndpointParams: url.Values{"extra": {"value"}},
+		Client:         httpclient.NewFrom(tokenServer.Client()),
+	}
+
+	token, errToken := cfg.ClientCredentials().Token(context.Background())
+
+	if errToken != nil {
+		t.Fatalf("token error: %v", errToken)
+	}
+	assertEqual(t, "access", token.AccessToken, "access token")
+	assertEqual(t, true, token.Expiry.IsZero(), "token without expiry")
+}
+
+func TestRefreshToken(t *testing.T) {
+	t.Parallel()
+
+	var refreshTokens []string
+	endpoint := &tokenEndpoint{
+		check: func(r *http.Request) {
+			assertEqual(t, "refresh_token", r.PostForm.Get("grant_type"), "grant type")
+			refreshTokens = append(refreshTokens, r.PostForm.Get("refresh_token"))
+		},
+		response: func(int32) (int, any) {
+			return http.StatusOK, map[string]any{
+				"access_token":  "access",
+				"refresh_token": "rotated",
+				// expires immediately, so every call refreshes the token
+				"expires_in": 1,
+			}
+		},
+	}
+	tokenServer := startEndpoint(t, endpoint)
+
+	cfg := &oauth2.Config{
+		ClientID: "id",
+		TokenURL: tokenServer.URL,
+		Client:   httpclient.NewFrom(tokenServer.Client()),
+	}
+
+	source := cfg.RefreshToken("initial")
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := source.Token(ctx); err != nil {
+			t.Fatalf("token error: %v", err)
+		}
+	}
+
+	assertEqual(t, 2, len(refreshTokens), "token requests")
+	assertEqual(t, "initial", refreshTokens[0], "first refresh token")
+	assertEqual(t, "rotated", refreshTokens[1], "rotated refresh token")
+	assertEqual(t, "rotated", source.RefreshToken(), "current refresh token")
+}
+
+func TestClientCredentials_Error(t *testing.T) {
+	t.Parallel()
+
+	endpoint := &tokenEndpoint{
+		response: func(int32) (int, any) {
+			return http.StatusBadRequest, map[string]any{
+				"error":             "invalid_client",
+				"error_description": "unknown client",
+			}
+		},
+	}
+	tokenServer := startEndpoint(t, endpoint)
+
+	cfg := &oauth2.Config{
+		ClientID: "id",
+		TokenURL: tokenServer.URL,
+		Client:   httpclient.NewFrom(tokenServer.Client()),
+	}
+
+	_, errToken := cfg.ClientCredentials().Token(context.Background())
+
+	var errResp *oauth2.Error
+	if !errors.As(errToken, &errResp) {
+		t.Fatalf("expected *oauth2.Error, got %v", errToken)
+	}
+	assertEqual(t, "invalid_client", errResp.Code, "error code")
+	assertEqual(t, "unknown client", errResp.Description, "error description")
+
+	var statusErr *httpclient.StatusError
+	assertEqual(t, true, errors.As(errToken, &statusErr), "status error")
+	assertEqual(t, http.StatusBadRequest, statusErr.StatusCode, "status code")
+}
+
+func TestTokenSource_BearerAuth(t *testing.T) {
+	t.Parallel()
+
+	endpoint := &tokenEndpoint{
+		response: func(int32) (int, any) {
+			return http.StatusOK, map[string]any{"access_token": "access", "token_type": "Bearer"}
+		},
+	}
+	tokenServer := startEndpoint(t, endpoint)
+
+	cfg := &oauth2.Config{
+		ClientID: "id",
+		TokenURL: tokenServer.URL,
+		Client:   httpclient.NewFrom(tokenServer.Client()),
+	}
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, "Bearer access", r.Header.Get("Authorization"), "authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(api.Close)
+
+	client := httpclient.NewFrom(api.Client())
+	client.Use(httpclient.BearerAuth(cfg.ClientCredentials()))
+
+	resp, errCall := client.Get(context.Background(), api.URL)
+	if errCall != nil {
+		t.Fatalf("call error: %v", errCall)
+	}
+	resp.Body.Close()
+}
+
+func must[E any](value E, err error) E {
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
@@ -0,0 +1,176 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RequestOption configures a single request.
+// Options are accepted by all the request methods of Client and take precedence over the Client defaults:
+// headers replace the Client.Header values, query parameters replace the Client.BaseURL,
+// address and GetForm parameters with the same keys.
+// Options are applied in order: repeated WithQuery and WithHeader options add values, so all of them are sent,
+// and a later WithTimeout replaces the earlier ones.
+type RequestOption func(options *requestOptions)
+
+type requestOptions struct {
+	pathParams map[string]string
+	form       url.Values
+	query      url.Values
+	header     http.Header
+	timeout    time.Duration
+	expect     []StatusRange
+}
+
+func newRequestOptions(opts []RequestOption) *requestOptions {
+	options := &requestOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// PathParam sets the value of the "{name}" placeholder in the request address path.
+// The value is escaped as a single path segment, the "." and ".." values are rejected.
+// The path template is used as the request route, see WithRoute.
+func PathParam(name, value string) RequestOption {
+	return func(options *requestOptions) {
+		if options.pathParams == nil {
+			options.pathParams = map[string]string{}
+		}
+		options.pathParams[name] = value
+	}
+}
+
+// withFormQuery sets the query parameters of the form, overriding the ones from the address.
+func withFormQuery(form url.Values) RequestOption {
+	return func(options *requestOptions) {
+		options.form = form
+	}
+}
+
+// WithQuery adds the value to the query parameter of a single request.
+// The parameters set by the options replace the BaseURL and address parameters with the same keys.
+func WithQuery(key, value string) RequestOption {
+	return func(options *requestOptions) {
+		if options.query == nil {
+			options.query = url.Values{}
+		}
+		options.query.Add(key, value)
+	}
+}
+
+// WithHeader adds the value to the header of a single request.
+// The headers set by the options replace the Client.Header and body Content-Type headers
+// with the same keys.
+func WithHeader(key, value string) RequestOption {
+	return func(options *requestOptions) {
+		if options.header == nil {
+			options.header = http.Header{}
+		}
+		options.header.Add(key, value)
+	}
+}
+
+// WithTimeout limits the time of a single request, including reading the response body.
+// The timeout is released when the response body is closed.
+func WithTimeout(timeout time.Duration) RequestOption {
+	return func(options *requestOptions) {
+		options.timeout = timeout
+	}
+}
+
+func withExpect(ranges ...StatusRange) RequestOption {
+	return func(options *requestOptions) {
+		options.expect = append(options.expect, ranges...)
+	}
+}
+
+// requestURL builds the request URL from the address template.
+// Query parameters are merged in the order: BaseURL, address, form, options.
+// BaseURL is not applied to absolute addresses, so its query parameters are not leaked to other hosts.
+// Every next source replaces the values of the keys it contains.
+func (client *Client) requestURL(addr string, options *requestOptions) (*url.URL, error) {
+	expanded, errExpand := expandPath(addr, options.pathParams)
+	if errExpand != nil {
+		return nil, errExpand
+	}
+
+	u, errParse := url.Parse(expanded)
+	if errParse != nil {
+		return nil, errParse
+	}
+
+	var query []url.Values
+	if client.BaseURL != nil && !u.IsAbs() && u.Host == "" {
+		query = append(query, client.BaseURL.Query())
+		u = client.BaseURL.ResolveReference(u)
+	}
+
+	if len(query) == 0 && len(options.form) == 0 && len(options.query) == 0 {
+		return u, nil
+	}
+
+	query = append(query, u.Query(), options.form, options.query)
+	u.RawQuery = mergeQuery(query...).Encode()
+
+	return u, nil
+}
+
+func mergeQuery(sources ...url.Values) url.Values {
+	merged := url.Values{}
+	for _, query := range sources {
+		for key, values := range query {
+			merged[key] = values
+		}
+	}
+	return merged
+}
+
+// expandPath replaces "{name}" placeholders in the path part of the address.
+func expandPath(addr string, params map[string]string) (string, error) {
+	end := strings.IndexAny(addr, "?#")
+	if end < 0 {
+		end = len(addr)
+	}
+	path, rest := addr[:end], addr[end:]
+
+	if !strings.Contains(path, "{") {
+		return addr, nil
+	}
+
+	expanded := strings.Builder{}
+	for {
+		start := strings.IndexByte(path, '{')
+		if start < 0 {
+			break
+		}
+
+		length := strings.IndexByte(path[start:], '}')
+		if length < 0 {
+			return "", fmt.Errorf("path template %q: unclosed placeholder", addr)
+		}
+
+		name := path[start+1 : start+length]
+		value, ok := params[name]
+		if !ok {
+			return "", fmt.Errorf("path template %q: parameter %q is not set", addr, name)
+		}
+
+		// dot segments and scheme-like first segments would escape the BaseURL
+		if value == "." || value == ".." {
+			return "", fmt.Errorf("path template %q: parameter %q: %q is not allowed", addr, name, value)
+		}
+
+		expanded.WriteString(path[:start])
+		expanded.WriteString(strings.ReplaceAll(url.PathEscape(value), ":", "%3A"))
+		path = path[start+length+1:]
+	}
+	expanded.WriteString(path)
+	expanded.WriteString(rest)
+
+	return expanded.String(), nil
+}
@@ -0,0 +1,247 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/ninedraft/httpclient"
+)
+
+func TestClient_BaseURL(t *testing.T) {
+	t.Parallel()
+
+	tc := func(name, base, addr, wantPath string, opts ...httpclient.RequestOption) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				assertEqual(t, wantPath, r.URL.EscapedPath(), "path")
+				w.WriteHeader(http.StatusOK)
+			})
+			defer server.Assert(t)
+
+			baseURL, errBase := url.Parse(server.URL + base)
+			requireEqual(t, nil, errBase, "parse base URL")
+
+			client := httpclient.NewFrom(server.Client())
+			client.BaseURL = baseURL
+
+			resp, errCall := client.Get(context.Background(), addr, opts...)
+
+			requireEqual(t, nil, errCall, "call error")
+			defer resp.Body.Close()
+			assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+		})
+	}
+
+	tc("relative", "/api/", "users", "/api/users")
+	tc("relative without slash", "/api", "users", "/users")
+	tc("absolute path", "/api/", "/users", "/users")
+	tc("parent", "/api/v1/", "../v2/users", "/api/v2/users")
+	tc("template", "/api/", "users/{id}/repos", "/api/users/a%2Fb%20c/repos",
+		httpclient.PathParam("id", "a/b c"))
+	tc("template with query", "/", "users/{id}?q={raw}", "/users/42",
+		httpclient.PathParam("id", "42"))
+	tc("template with scheme-like value", "/api/", "{id}/repos", "/api/http%3Aevil/repos",
+		httpclient.PathParam("id", "http:evil"))
+}
+
+func TestClient_PathParamDotSegments(t *testing.T) {
+	t.Parallel()
+
+	client := httpclient.NewFrom(httpclient.DoerFunc(func(*http.Request) (*http.Response, error) {
+		t.Error("doer must not be called")
+		return nil, nil
+	}))
+	client.BaseURL = &url.URL{Scheme: "https", Host: "api.example.com", Path: "/v1/"}
+
+	for _, value := range []string{".", ".."} {
+		_, errCall := client.Get(context.Background(), "users/{id}/repos", httpclient.PathParam("id", value))
+		assertNotEqual(t, nil, errCall, "call error for %q", value)
+	}
+}
+
+func TestClient_BaseURLAbsolute(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, "/other", r.URL.Path, "path")
+		assertEqual(t, "", r.URL.Query().Get("key"), "base query must not be sent")
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.BaseURL = &url.URL{Scheme: "http", Host: "example.com", Path: "/api/", RawQuery: "key=secret"}
+
+	resp, errCall := client.Get(context.Background(), server.URL+"/other")
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+}
+
+func TestClient_QueryMerge(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, "a=base&b=addr&c=form&d=form", r.URL.RawQuery, "query")
+		assertEqual(t, "/api/items/7", r.URL.Path, "path")
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	baseURL, errBase := url.Parse(server.URL + "/api/?a=base&b=base")
+	requireEqual(t, nil, errBase, "parse base URL")
+
+	client := httpclient.NewFrom(server.Client())
+	client.BaseURL = baseURL
+
+	resp, errCall := client.GetForm(context.Background(), "items/{id}?b=addr&c=addr",
+		url.Values{"c": {"form"}, "d": {"form"}},
+		httpclient.PathParam("id", "7"))
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+}
+
+func TestClient_PathParamMissing(t *testing.T) {
+	t.Parallel()
+
+	client := httpclient.NewFrom(httpclient.DoerFunc(func(*http.Request) (*http.Response, error) {
+		t.Error("doer must not be called")
+		return nil, nil
+	}))
+
+	_, errCall := client.PostJSON(context.Background(), "http://example.com/users/{id}", nil)
+
+	assertNotEqual(t, nil, errCall, "call error")
+}
+
+func TestClient_RequestOptions(t *testing.T) {
+	t.Parallel()
+
+	calls := map[string]callFn{}
+	for method, call := range methodsBody {
+		calls[method] = call
+	}
+	for method, call := range methodsNoBody {
+		calls[method] = call
+	}
+	for method, call := range methodsForms {
+		call := call
+		calls[method+" form"] = func(cl *httpclient.Client, ctx context.Context, addr string, opts ...httpclient.RequestOption) (*http.Response, error) {
+			return call(cl, ctx, addr, url.Values{"form": {"value"}}, opts...)
+		}
+	}
+	for method, call := range methodsJSON {
+		call := call
+		calls[method+" json"] = func(cl *httpclient.Client, ctx context.Context, addr string, opts ...httpclient.RequestOption) (*http.Response, error) {
+			return call(cl, ctx, addr, "value", opts...)
+		}
+	}
+	for method, call := range methodsMultiPart {
+		call := call
+		calls[method+" multipart"] = func(cl *httpclient.Client, ctx context.Context, addr string, opts ...httpclient.RequestOption) (*http.Response, error) {
+			return call(cl, ctx, addr, httpclient.MultipartFields(url.Values{"form": {"value"}}), opts...)
+		}
+	}
+
+	for name, call := range calls {
+		name, call := name, call
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				assertEqualSlices(t, []string{"request-1", "request-2"}, r.Header.Values("X-Request-Id"), "request header")
+				assertEqual(t, "client", r.Header.Get("X-Client"), "client header")
+				assertEqual(t, "option", r.URL.Query().Get("q"), "query")
+				assertEqual(t, "addr", r.URL.Query().Get("keep"), "address query")
+				w.WriteHeader(http.StatusOK)
+			})
+			defer server.Assert(t)
+
+			client := httpclient.NewFrom(server.Client())
+			client.Header.Set("X-Request-Id", "client")
+			client.Header.Set("X-Client", "client")
+
+			resp, errCall := call(client, context.Background(), server.URL+"/path?q=addr&keep=addr",
+				httpclient.WithHeader("X-Request-Id", "request-1"),
+				httpclient.WithHeader("X-Request-Id", "request-2"),
+				httpclient.WithQuery("q", "option"),
+				httpclient.WithTimeout(time.Minute))
+
+			requireEqual(t, nil, errCall, "call error")
+			defer resp.Body.Close()
+			assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+		})
+	}
+}
+
+func TestClient_OptionsPrecedence(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqualSlices(t, []string{"option-1", "option-2"}, r.URL.Query()["q"], "query")
+		assertEqualSlices(t, []string{"option-1", "option-2"}, r.Header.Values("X-Request-Id"), "header")
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Header.Set("X-Request-Id", "client")
+
+	resp, errCall := client.GetForm(context.Background(), server.URL+"?q=addr", url.Values{"q": {"form"}},
+		httpclient.WithQuery("q", "option-1"),
+		httpclient.WithQuery("q", "option-2"),
+		httpclient.WithHeader("X-Request-Id", "option-1"),
+		httpclient.WithHeader("X-Request-Id", "option-2"),
+		// the later timeout replaces the earlier one
+		httpclient.WithTimeout(time.Nanosecond),
+		httpclient.WithTimeout(time.Minute))
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+	assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+}
+
+func TestClient_WithHeaderContentType(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, "application/merge-patch+json", r.Header.Get("Content-Type"), "content type")
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+
+	resp, errCall := client.PatchJSON(context.Background(), server.URL, map[string]string{"foo": "bar"},
+		httpclient.WithHeader("Content-Type", "application/merge-patch+json"))
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+}
+
+func TestClient_WithTimeout(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	defer server.Assert(t)
+	defer close(release)
+
+	client := httpclient.NewFrom(server.Client())
+
+	_, errCall := client.Get(context.Background(), server.URL, httpclient.WithTimeout(10*time.Millisecond))
+
+	assertEqual(t, true, errors.Is(errCall, context.DeadlineExceeded), "expected deadline error, got %v", errCall)
+}
@@ -0,0 +1,18 @@
+module github.com/ninedraft/httpclient/oteltrace
+
+go 1.21
+
+require (
+	github.com/ninedraft/httpclient v0.0.0-20261017211734-7a646beba9c4
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/sdk v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.1 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/otel/metric v1.24.0 // indirect
+	golang.org/x/exp v0.0.0-20230213192124-5e25df0256eb // indirect
+	golang.org/x/sys v0.18.0 // indirect
+)
@@ -0,0 +1,115 @@
+// Package oteltrace adapts OpenTelemetry tracing to httpclient.Tracer.
+//
+// It is a separate module, so the httpclient module does not depend on OpenTelemetry:
+//
+//	client.Use(httpclient.Tracing(httpclient.TracingOptions{
+//		Tracer: oteltrace.NewTracer(nil),
+//	}))
+//
+// Client spans are children of the OpenTelemetry span in the request context.
+// If there is none, the span context set by httpclient.WithSpanContext
+// or httpclient.ExtractTraceContext is used as a remote parent.
+package oteltrace
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ninedraft/httpclient"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope name of the tracer.
+const ScopeName = "github.com/ninedraft/httpclient"
+
+var _ httpclient.Tracer = (*Tracer)(nil)
+
+// Tracer starts OpenTelemetry client spans.
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer returns a tracer which uses the provider.
+// If the provider is nil, the global tracer provider is used.
+func NewTracer(provider trace.TracerProvider) *Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return &Tracer{tracer: provider.Tracer(ScopeName)}
+}
+
+// Start starts a client span.
+func (tracer *Tracer) Start(ctx context.Context, name string) (context.Context, httpclient.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		if parent, ok := remoteParent(httpclient.SpanContextFrom(ctx)); ok {
+			ctx = trace.ContextWithRemoteSpanContext(ctx, parent)
+		}
+	}
+
+	ctx, span := tracer.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, &otelSpan{span: span}
+}
+
+func remoteParent(sc httpclient.SpanContext) (trace.SpanContext, bool) {
+	if !sc.IsValid() {
+		return trace.SpanContext{}, false
+	}
+
+	// an invalid tracestate is dropped, the trace is continued without it
+	state, _ := trace.ParseTraceState(sc.TraceState)
+
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    sc.TraceID,
+		SpanID:     sc.SpanID,
+		TraceFlags: trace.TraceFlags(sc.Flags),
+		TraceState: state,
+		Remote:     true,
+	}), true
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (span *otelSpan) SpanContext() httpclient.SpanContext {
+	sc := span.span.SpanContext()
+	return httpclient.SpanContext{
+		TraceID:    sc.TraceID(),
+		SpanID:     sc.SpanID(),
+		Flags:      byte(sc.TraceFlags()),
+		TraceState: sc.TraceState().String(),
+	}
+}
+
+func (span *otelSpan) SetAttribute(key string, value any) {
+	span.span.SetAttributes(keyValue(key, value))
+}
+
+func (span *otelSpan) SetError(err error) {
+	span.span.RecordError(err)
+	span.span.SetStatus(codes.Error, err.Error())
+}
+
+func (span *otelSpan) End() {
+	span.span.End()
+}
+
+func keyValue(key string, value any) attribute.KeyValue {
+	switch value := value.(type) {
+	case string:
+		return attribute.String(key, value)
+	case int:
+		return attribute.Int(key, value)
+	case int64:
+		return attribute.Int64(key, value)
+	case float64:
+		return attribute.Float64(key, value)
+	case bool:
+		return attribute.Bool(key, value)
+	default:
+		return attribute.String(key, fmt.Sprint(value))
+	}
+}
@@ -0,0 +1,133 @@
+package oteltrace_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ninedraft/httpclient"
+	"github.com/ninedraft/httpclient/oteltrace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newProvider() (*sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	return sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)), recorder
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTracer(t *testing.T) {
+	t.Parallel()
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+	}))
+	defer server.Close()
+
+	provider, recorder := newProvider()
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Tracing(httpclient.TracingOptions{Tracer: oteltrace.NewTracer(provider)}))
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	resp, err := client.Get(ctx, server.URL+"/users/{id}", httpclient.PathParam("id", "42"))
+	if err != nil {
+		t.Fatalf("call error: %v", err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("spans: want 2, got %d", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name() != "GET /users/{id}" {
+		t.Errorf("span name: %q", span.Name())
+	}
+	if span.SpanKind() != trace.SpanKindClient {
+		t.Errorf("span kind: %v", span.SpanKind())
+	}
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("span parent: want %v, got %v", parent.SpanContext().SpanID(), span.Parent().SpanID())
+	}
+
+	expected := httpclient.SpanContext{
+		TraceID: span.SpanContext().TraceID(),
+		SpanID:  span.SpanContext().SpanID(),
+		Flags:   byte(span.SpanContext().TraceFlags()),
+	}
+	if traceparent != expected.Traceparent() {
+		t.Errorf("traceparent: want %q, got %q", expected.Traceparent(), traceparent)
+	}
+
+	attrs := attributes(span)
+	if got := attrs["http.response.status_code"].AsInt64(); got != http.StatusOK {
+		t.Errorf("status code attribute: %d", got)
+	}
+	if got := attrs["url.template"].AsString(); got != "/users/{id}" {
+		t.Errorf("url template attribute: %q", got)
+	}
+	if got := attrs["http.request.method"].AsString(); got != http.MethodGet {
+		t.Errorf("method attribute: %q", got)
+	}
+}
+
+func TestTracer_RemoteParent(t *testing.T) {
+	t.Parallel()
+
+	provider, recorder := newProvider()
+	client := httpclient.NewFrom(httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	}))
+	client.Use(httpclient.Tracing(httpclient.TracingOptions{Tracer: oteltrace.NewTracer(provider)}))
+
+	header := http.Header{}
+	header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set("Tracestate", "vendor=value")
+	ctx := httpclient.ExtractTraceContext(context.Background(), header)
+
+	_, err := client.Get(ctx, "http://example.com/")
+	if err == nil {
+		t.Fatalf("call error is expected")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("spans: want 1, got %d", len(spans))
+	}
+
+	span := spans[0]
+	if got := span.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace id: %s", got)
+	}
+	if got := span.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("parent span id: %s", got)
+	}
+	if !span.Parent().IsRemote() {
+		t.Errorf("parent is not remote")
+	}
+	if got := span.SpanContext().TraceState().String(); got != "vendor=value" {
+		t.Errorf("tracestate: %q", got)
+	}
+	if span.Status().Code != codes.Error {
+		t.Errorf("span status: %v", span.Status())
+	}
+	if len(span.Events()) != 1 || span.Events()[0].Name != "exception" {
+		t.Errorf("error is not recorded: %v", span.Events())
+	}
+}
@@ -0,0 +1,138 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const mediaTypeProblem = "application/problem+json"
+
+// Problem is a problem details object as defined by RFC 9457.
+//
+// Responses with the "application/problem+json" media type are decoded into Problem
+// and can be extracted from *StatusError using errors.As.
+type Problem struct {
+	// Type is a URI reference that identifies the problem type.
+	// It is "about:blank" if the member is not present.
+	Type string
+	// Title is a short, human-readable summary of the problem type.
+	Title string
+	// Status is the HTTP status code generated by the origin server.
+	Status int
+	// Detail is a human-readable explanation specific to this occurrence of the problem.
+	Detail string
+	// Instance is a URI reference that identifies the specific occurrence of the problem.
+	Instance string
+	// Extensions contains all other members of the problem object.
+	Extensions map[string]any
+}
+
+var _ json.Unmarshaler = (*Problem)(nil)
+
+// UnmarshalJSON decodes the problem details object.
+// Members with unexpected types are ignored, as recommended by RFC 9457.
+func (problem *Problem) UnmarshalJSON(data []byte) error {
+	members := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+
+	*problem = Problem{Type: "about:blank"}
+
+	for name, raw := range members {
+		switch name {
+		case "type":
+			unmarshalMember(raw, &problem.Type)
+		case "title":
+			unmarshalMember(raw, &problem.Title)
+		case "status":
+			unmarshalMember(raw, &problem.Status)
+		case "detail":
+			unmarshalMember(raw, &problem.Detail)
+		case "instance":
+			unmarshalMember(raw, &problem.Instance)
+		default:
+			var value any
+			if json.Unmarshal(raw, &value) != nil {
+				continue
+			}
+			if problem.Extensions == nil {
+				problem.Extensions = map[string]any{}
+			}
+			problem.Extensions[name] = value
+		}
+	}
+
+	return nil
+}
+
+func unmarshalMember[E any](raw json.RawMessage, dst *E) {
+	var value E
+	if json.Unmarshal(raw, &value) == nil {
+		*dst = value
+	}
+}
+
+var _ json.Marshaler = Problem{}
+
+// MarshalJSON encodes the problem details object.
+// Extension members never override the standard ones.
+func (problem Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]any, len(problem.Extensions)+5)
+	for name, value := range problem.Extensions {
+		members[name] = value
+	}
+
+	setMember := func(name string, value any, isZero bool) {
+		if isZero {
+			delete(members, name)
+			return
+		}
+		members[name] = value
+	}
+
+	setMember("type", problem.Type, problem.Type == "")
+	setMember("title", problem.Title, problem.Title == "")
+	setMember("status", problem.Status, problem.Status == 0)
+	setMember("detail", problem.Detail, problem.Detail == "")
+	setMember("instance", problem.Instance, problem.Instance == "")
+
+	return json.Marshal(members)
+}
+
+func (problem *Problem) Error() string {
+	title := problem.Title
+	if title == "" {
+		title = http.StatusText(problem.Status)
+	}
+	if title == "" {
+		title = problem.Type
+	}
+
+	if problem.Detail == "" {
+		return "problem: " + title
+	}
+
+	return "problem: " + title + ": " + problem.Detail
+}
+
+func isProblem(header http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(header.Get(headerContentType))
+	return err == nil && strings.EqualFold(mediaType, mediaTypeProblem)
+}
+
+// parseProblem decodes the body snapshot if it is a problem details object.
+func parseProblem(header http.Header, body []byte) *Problem {
+	if !isProblem(header) {
+		return nil
+	}
+
+	problem := &Problem{}
+	if err := json.Unmarshal(body, problem); err != nil {
+		return nil
+	}
+
+	return problem
+}
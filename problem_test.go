@@ -0,0 +1,88 @@
+package httpclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/ninedraft/httpclient"
+)
+
+func TestClient_Problem(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = io.WriteString(w, `{
+			"type": "https://example.com/probs/out-of-credit",
+			"title": "You do not have enough credit.",
+			"status": 403,
+			"detail": "Your current balance is 30, but that costs 50.",
+			"instance": "/account/12345/msgs/abc",
+			"balance": 30
+		}`)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+
+	_, errCall := httpclient.GetJSON[map[string]any](context.Background(), client, server.URL)
+
+	var problem *httpclient.Problem
+	requireEqual(t, true, errors.As(errCall, &problem), "expected problem, got %v", errCall)
+	assertEqual(t, "https://example.com/probs/out-of-credit", problem.Type, "type")
+	assertEqual(t, "You do not have enough credit.", problem.Title, "title")
+	assertEqual(t, http.StatusForbidden, problem.Status, "status")
+	assertEqual(t, "Your current balance is 30, but that costs 50.", problem.Detail, "detail")
+	assertEqual(t, "/account/12345/msgs/abc", problem.Instance, "instance")
+	assertEqual(t, any(30.0), problem.Extensions["balance"], "balance extension")
+}
+
+func TestClient_NotProblem(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"title": "bad request"}`)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+
+	_, errCall := httpclient.GetJSON[map[string]any](context.Background(), client, server.URL)
+
+	var problem *httpclient.Problem
+	assertEqual(t, false, errors.As(errCall, &problem), "unexpected problem")
+}
+
+func TestProblem_UnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	problem := httpclient.Problem{}
+	errUnmarshal := json.Unmarshal([]byte(`{"status": "not a number", "title": "Oops"}`), &problem)
+
+	requireEqual(t, nil, errUnmarshal, "unmarshal error")
+	assertEqual(t, "about:blank", problem.Type, "default type")
+	assertEqual(t, 0, problem.Status, "ignored status")
+	assertEqual(t, "Oops", problem.Title, "title")
+}
+
+func TestProblem_MarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	problem := httpclient.Problem{
+		Type:       "about:blank",
+		Status:     http.StatusNotFound,
+		Extensions: map[string]any{"status": "ignored", "trace": "abc"},
+	}
+
+	data, errMarshal := json.Marshal(problem)
+
+	requireEqual(t, nil, errMarshal, "marshal error")
+	assertEqual(t, `{"status":404,"trace":"abc","type":"about:blank"}`, string(data), "problem json")
+}
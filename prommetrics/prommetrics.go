@@ -0,0 +1,310 @@
+// Package prommetrics implements httpclient.Metrics with the Prometheus text exposition format.
+//
+// A Registry collects the metrics of the httpclient.Instrument wrapper
+// and serves them to a Prometheus scraper:
+//
+//	registry := prommetrics.NewRegistry()
+//	client.Use(httpclient.Instrument(httpclient.MetricsOptions{Metrics: registry}))
+//	http.Handle("/metrics", registry)
+package prommetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ninedraft/httpclient"
+)
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+var _ httpclient.Metrics = (*Registry)(nil)
+
+// Registry is a set of metric families. It is safe for concurrent use.
+//
+// Instruments created with the same name share the metric family,
+// so several clients can report to one registry.
+// Creating an instrument with the name of a family of another type or with other label names panics.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*family{}}
+}
+
+// Counter returns the counter family with the given name, creating it if needed.
+// Adding a negative value to the counter panics.
+func (registry *Registry) Counter(name, help string, labels ...string) httpclient.Counter {
+	return &counter{registry.family(name, help, typeCounter, nil, labels)}
+}
+
+// Gauge returns the gauge family with the given name, creating it if needed.
+func (registry *Registry) Gauge(name, help string, labels ...string) httpclient.Gauge {
+	return &gauge{registry.family(name, help, typeGauge, nil, labels)}
+}
+
+// Histogram returns the histogram family with the given name, creating it if needed.
+// The buckets are the upper bounds of the observed values, the +Inf bucket is implicit.
+func (registry *Registry) Histogram(name, help string, buckets []float64, labels ...string) httpclient.Histogram {
+	bounds := make([]float64, 0, len(buckets))
+	for _, bound := range buckets {
+		if !math.IsInf(bound, +1) {
+			bounds = append(bounds, bound)
+		}
+	}
+	sort.Float64s(bounds)
+
+	return &histogram{registry.family(name, help, typeHistogram, bounds, labels)}
+}
+
+func (registry *Registry) family(name, help, kind string, buckets []float64, labels []string) *family {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if existing, ok := registry.families[name]; ok {
+		if existing.kind != kind || strings.Join(existing.labels, ",") != strings.Join(labels, ",") {
+			panic(fmt.Sprintf("prommetrics: %s is already registered as %s%v", name, existing.kind, existing.labels))
+		}
+		return existing
+	}
+
+	created := &family{
+		registry: registry,
+		name:     name,
+		help:     help,
+		kind:     kind,
+		labels:   append([]string(nil), labels...),
+		buckets:  buckets,
+		series:   map[string]*series{},
+	}
+	registry.families[name] = created
+	return created
+}
+
+// ServeHTTP writes the metrics in the text exposition format.
+func (registry *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_, _ = registry.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the text exposition format.
+// Families and series are sorted, families without series are omitted.
+// The metrics are copied under the lock, so a slow writer doesn't block updates.
+func (registry *Registry) WriteTo(w io.Writer) (int64, error) {
+	buf := &countingWriter{w: bufio.NewWriter(w)}
+
+	for _, family := range registry.snapshot() {
+		family.write(buf)
+	}
+
+	if buf.err != nil {
+		return buf.n, buf.err
+	}
+	return buf.n, buf.w.Flush()
+}
+
+// snapshot returns copies of the families sorted by name.
+func (registry *Registry) snapshot() []*family {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	families := make([]*family, 0, len(registry.families))
+	for _, found := range registry.families {
+		families = append(families, found.snapshot())
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+	return families
+}
+
+type family struct {
+	registry *Registry
+	name     string
+	help     string
+	kind     string
+	labels   []string
+	buckets  []float64
+	series   map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	// value is the counter or gauge value, or the histogram sum.
+	value float64
+	// counts are the histogram bucket counts, the last one is the +Inf bucket.
+	counts []uint64
+}
+
+// get returns the series with the label values. The registry lock must be held.
+func (family *family) get(labelValues []string) *series {
+	if len(labelValues) != len(family.labels) {
+		panic(fmt.Sprintf("prommetrics: %s expects %d label values, got %d", family.name, len(family.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	found, ok := family.series[key]
+	if !ok {
+		found = &series{labelValues: append([]string(nil), labelValues...)}
+		if family.kind == typeHistogram {
+			found.counts = make([]uint64, len(family.buckets)+1)
+		}
+		family.series[key] = found
+	}
+	return found
+}
+
+// snapshot returns a copy of the family with copies of its series. The registry lock must be held.
+func (family *family) snapshot() *family {
+	copied := *family
+	copied.series = make(map[string]*series, len(family.series))
+	for key, found := range family.series {
+		copied.series[key] = &series{
+			labelValues: found.labelValues,
+			value:       found.value,
+			counts:      append([]uint64(nil), found.counts...),
+		}
+	}
+	return &copied
+}
+
+func (family *family) write(w *countingWriter) {
+	if len(family.series) == 0 {
+		return
+	}
+
+	w.printf("# HELP %s %s\n", family.name, escapeHelp(family.help))
+	w.printf("# TYPE %s %s\n", family.name, family.kind)
+
+	keys := make([]string, 0, len(family.series))
+	for key := range family.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := family.series[key]
+		if family.kind != typeHistogram {
+			w.printf("%s%s %s\n", family.name, family.labelPairs(s.labelValues, ""), formatFloat(s.value))
+			continue
+		}
+
+		var cumulative uint64
+		for i, count := range s.counts {
+			cumulative += count
+			le := "+Inf"
+			if i < len(family.buckets) {
+				le = formatFloat(family.buckets[i])
+			}
+			w.printf("%s_bucket%s %d\n", family.name, family.labelPairs(s.labelValues, le), cumulative)
+		}
+		w.printf("%s_sum%s %s\n", family.name, family.labelPairs(s.labelValues, ""), formatFloat(s.value))
+		w.printf("%s_count%s %d\n", family.name, family.labelPairs(s.labelValues, ""), cumulative)
+	}
+}
+
+// labelPairs formats the label set, with the histogram "le" label if not empty.
+func (family *family) labelPairs(labelValues []string, le string) string {
+	if len(labelValues) == 0 && le == "" {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labelValues)+1)
+	for i, value := range labelValues {
+		pairs = append(pairs, family.labels[i]+`="`+escapeLabelValue(value)+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+type counter struct{ family *family }
+
+func (c *counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("prommetrics: counter " + c.family.name + " cannot decrease")
+	}
+
+	c.family.registry.mu.Lock()
+	defer c.family.registry.mu.Unlock()
+	c.family.get(labelValues).value += delta
+}
+
+type gauge struct{ family *family }
+
+func (g *gauge) Add(delta float64, labelValues ...string) {
+	g.family.registry.mu.Lock()
+	defer g.family.registry.mu.Unlock()
+	g.family.get(labelValues).value += delta
+}
+
+type histogram struct{ family *family }
+
+func (h *histogram) Observe(value float64, labelValues ...string) {
+	bucket := sort.SearchFloat64s(h.family.buckets, value)
+
+	h.family.registry.mu.Lock()
+	defer h.family.registry.mu.Unlock()
+	s := h.family.get(labelValues)
+	s.counts[bucket]++
+	s.value += value
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, +1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+// countingWriter counts the written bytes and keeps the first error.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) printf(format string, args ...any) {
+	if w.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(w.w, format, args...)
+	w.n += int64(n)
+	w.err = err
+}
@@ -0,0 +1,223 @@
+package prommetrics_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ninedraft/httpclient"
+	"github.com/ninedraft/httpclient/prommetrics"
+)
+
+func exposition(t *testing.T, registry *prommetrics.Registry) string {
+	t.Helper()
+
+	buf := &strings.Builder{}
+	n, err := registry.WriteTo(buf)
+	if err != nil {
+		t.Fatalf("write metrics: %v", err)
+	}
+	if int(n) != buf.Len() {
+		t.Errorf("written bytes: want %d, got %d", buf.Len(), n)
+	}
+	return buf.String()
+}
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	registry := prommetrics.NewRegistry()
+
+	requests := registry.Counter("requests_total", "Total requests.", "method", "status")
+	requests.Add(1, "GET", "2xx")
+	requests.Add(2, "GET", "2xx")
+	requests.Add(1, "POST", "5xx")
+
+	inFlight := registry.Gauge("in_flight", "In-flight requests.")
+	inFlight.Add(2)
+	inFlight.Add(-1)
+
+	duration := registry.Histogram("duration_seconds", "Request duration.", []float64{1, 0.1, 0.5}, "method")
+	duration.Observe(0.05, "GET")
+	duration.Observe(0.5, "GET")
+	duration.Observe(3, "GET")
+
+	registry.Counter("unused_total", "No series.", "method")
+
+	expected := `# HELP duration_seconds Request duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{method="GET",le="0.1"} 1
+duration_seconds_bucket{method="GET",le="0.5"} 2
+duration_seconds_bucket{method="GET",le="1"} 2
+duration_seconds_bucket{method="GET",le="+Inf"} 3
+duration_seconds_sum{method="GET"} 3.55
+duration_seconds_count{method="GET"} 3
+# HELP in_flight In-flight requests.
+# TYPE in_flight gauge
+in_flight 1
+# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{method="GET",status="2xx"} 3
+requests_total{method="POST",status="5xx"} 1
+`
+	got := exposition(t, registry)
+	if got != expected {
+		t.Errorf("exposition:\nwant:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestRegistry_Escaping(t *testing.T) {
+	t.Parallel()
+
+	registry := prommetrics.NewRegistry()
+	registry.Counter("escaped_total", "Help with \\ and\nnewline.", "path").
+		Add(1, "/a\"b\\c\nd")
+
+	expected := `# HELP escaped_total Help with \\ and\nnewline.
+# TYPE escaped_total counter
+escaped_total{path="/a\"b\\c\nd"} 1
+`
+	got := exposition(t, registry)
+	if got != expected {
+		t.Errorf("exposition:\nwant:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestRegistry_SharedFamily(t *testing.T) {
+	t.Parallel()
+
+	registry := prommetrics.NewRegistry()
+	registry.Counter("shared_total", "Shared.", "client").Add(1, "a")
+	registry.Counter("shared_total", "Shared.", "client").Add(1, "a")
+
+	got := exposition(t, registry)
+	if !strings.Contains(got, `shared_total{client="a"} 2`) {
+		t.Errorf("shared family is not summed:\n%s", got)
+	}
+}
+
+// blockingWriter blocks the first write until it is released.
+type blockingWriter struct {
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	select {
+	case <-w.writing:
+	default:
+		close(w.writing)
+		<-w.release
+	}
+	return len(p), nil
+}
+
+func TestRegistry_SlowWriter(t *testing.T) {
+	t.Parallel()
+
+	registry := prommetrics.NewRegistry()
+	requests := registry.Counter("requests_total", "Total requests.", "path")
+	// enough series to fill the write buffer
+	for i := 0; i < 200; i++ {
+		requests.Add(1, fmt.Sprintf("/%s/%d", strings.Repeat("x", 32), i))
+	}
+
+	w := &blockingWriter{writing: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan error, 1)
+	go func() {
+		_, err := registry.WriteTo(w)
+		done <- err
+	}()
+	<-w.writing
+
+	updated := make(chan struct{})
+	go func() {
+		requests.Add(1, "/")
+		close(updated)
+	}()
+
+	select {
+	case <-updated:
+	case <-time.After(time.Second):
+		t.Error("update is blocked by a slow writer")
+	}
+
+	close(w.release)
+	if err := <-done; err != nil {
+		t.Errorf("write metrics: %v", err)
+	}
+	<-updated
+}
+
+func TestRegistry_Panics(t *testing.T) {
+	t.Parallel()
+
+	registry := prommetrics.NewRegistry()
+	registry.Counter("requests_total", "Total requests.", "method")
+
+	tcs := map[string]func(){
+		"other type":         func() { registry.Gauge("requests_total", "", "method") },
+		"other labels":       func() { registry.Counter("requests_total", "", "host") },
+		"wrong label values": func() { registry.Counter("requests_total", "", "method").Add(1) },
+		"negative counter":   func() { registry.Counter("requests_total", "", "method").Add(-1, "GET") },
+	}
+
+	for name, fn := range tcs {
+		fn := fn
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				if recover() == nil {
+					t.Errorf("no panic")
+				}
+			}()
+			fn()
+		})
+	}
+}
+
+func TestRegistry_Instrument(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello")
+	}))
+	defer server.Close()
+
+	registry := prommetrics.NewRegistry()
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Instrument(httpclient.MetricsOptions{Metrics: registry}))
+
+	resp, err := client.Get(context.Background(), server.URL+"/users/{id}", httpclient.PathParam("id", "42"))
+	if err != nil {
+		t.Fatalf("call error: %v", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	recorder := httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if got := recorder.Header().Get("Content-Type"); got != prommetrics.ContentType {
+		t.Errorf("content type: want %q, got %q", prommetrics.ContentType, got)
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	body := recorder.Body.String()
+	for _, line := range []string{
+		`httpclient_requests_total{method="GET",host="` + host + `",route="/users/{id}",status="2xx"} 1`,
+		`httpclient_request_duration_seconds_count{method="GET",host="` + host + `",route="/users/{id}",status="2xx"} 1`,
+		`httpclient_response_bytes_total{method="GET",host="` + host + `",route="/users/{id}"} 5`,
+		`httpclient_requests_in_flight{method="GET",host="` + host + `",route="/users/{id}"} 0`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics do not contain %s:\n%s", line, body)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit configures a token bucket.
+type Limit struct {
+	// Rate is the number of requests per second. Zero means no limit.
+	Rate float64
+	// Burst is the maximum number of requests sent at once. Default is 1.
+	Burst int
+}
+
+func (limit Limit) enabled() bool {
+	return limit.Rate > 0
+}
+
+// RateLimitOptions configures the RateLimit wrapper.
+type RateLimitOptions struct {
+	// Global limits all requests passing through the wrapper.
+	Global Limit
+
+	// PerHost limits requests to every host independently.
+	PerHost Limit
+
+	// Hosts overrides PerHost for specific hosts.
+	// Keys are matched against the request URL host with and without the port.
+	Hosts map[string]Limit
+
+	// Adaptive pauses requests to a host when its responses report an exhausted quota
+	// using RateLimit, RateLimit-Remaining/RateLimit-Reset, X-RateLimit-Remaining/X-RateLimit-Reset
+	// headers, or a 429 response with the Retry-After header.
+	Adaptive bool
+}
+
+// RateLimit creates a wrapper which throttles outgoing requests using token buckets.
+// Requests wait for their turn until the request context is done.
+func RateLimit(opts RateLimitOptions) Wrapper {
+	limiter := &rateLimiter{
+		opts:  opts,
+		hosts: map[string]*hostLimiter{},
+	}
+	if opts.Global.enabled() {
+		limiter.global = newTokenBucket(opts.Global, time.Now())
+	}
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return limiter.do(next, req)
+		})
+	}
+}
+
+type rateLimiter struct {
+	opts   RateLimitOptions
+	global *tokenBucket
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimiter
+}
+
+type hostLimiter struct {
+	bucket *tokenBucket
+
+	mu           sync.Mutex
+	blockedUntil time.Time
+}
+
+func (limiter *rateLimiter) do(next Doer, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	host := limiter.host(req.URL)
+
+	if errWait := limiter.wait(ctx, host); errWait != nil {
+		closeRequestBody(req, errWait)
+		return nil, errWait
+	}
+
+	resp, err := next.Do(req)
+	if err == nil && limiter.opts.Adaptive {
+		if until, ok := rateLimitReset(resp, time.Now()); ok {
+			host.block(until)
+		}
+	}
+
+	return resp, err
+}
+
+// wait waits for the global and the host limits.
+// The global token is returned if the host wait fails, so it isn't lost for other hosts.
+func (limiter *rateLimiter) wait(ctx context.Context, host *hostLimiter) error {
+	if errWait := host.waitUnblocked(ctx); errWait != nil {
+		return errWait
+	}
+
+	if limiter.global != nil {
+		if errWait := limiter.global.wait(ctx); errWait != nil {
+			return errWait
+		}
+	}
+
+	if host.bucket != nil {
+		if errWait := host.bucket.wait(ctx); errWait != nil {
+			if limiter.global != nil {
+				limiter.global.cancel()
+			}
+			return errWait
+		}
+	}
+
+	return nil
+}
+
+func (limiter *rateLimiter) host(u *url.URL) *hostLimiter {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	host := limiter.hosts[u.Host]
+	if host != nil {
+		return host
+	}
+
+	host = &hostLimiter{}
+	if limit := limiter.hostLimit(u); limit.enabled() {
+		host.bucket = newTokenBucket(limit, time.Now())
+	}
+	limiter.hosts[u.Host] = host
+
+	return host
+}
+
+func (limiter *rateLimiter) hostLimit(u *url.URL) Limit {
+	if limit, ok := limiter.opts.Hosts[u.Host]; ok {
+		return limit
+	}
+	if limit, ok := limiter.opts.Hosts[u.Hostname()]; ok {
+		return limit
+	}
+	return limiter.opts.PerHost
+}
+
+func (host *hostLimiter) waitUnblocked(ctx context.Context) error {
+	host.mu.Lock()
+	until := host.blockedUntil
+	host.mu.Unlock()
+
+	if delay := time.Until(until); delay > 0 {
+		return sleep(ctx, delay)
+	}
+	return nil
+}
+
+func (host *hostLimiter) block(until time.Time) {
+	host.mu.Lock()
+	defer host.mu.Unlock()
+
+	if until.After(host.blockedUntil) {
+		host.blockedUntil = until
+	}
+}
+
+// rateLimitReset returns the time when the exhausted quota is restored.
+func rateLimitReset(resp *http.Response, now time.Time) (time.Time, bool) {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if delay, ok := parseRetryAfter(resp.Header, now); ok {
+			return now.Add(delay), true
+		}
+	}
+
+	remaining, reset, ok := parseRateLimitField(resp.Header.Get("RateLimit"))
+	if !ok {
+		remaining, reset, ok = parseRateLimitHeaders(resp.Header, "RateLimit-")
+	}
+	if !ok {
+		remaining, reset, ok = parseRateLimitHeaders(resp.Header, "X-RateLimit-")
+	}
+	if !ok || remaining > 0 {
+		return time.Time{}, false
+	}
+
+	// large values are unix timestamps, small ones are delays in seconds
+	const epochThreshold = 1_000_000_000
+	if reset >= epochThreshold {
+		return time.Unix(reset, 0), true
+	}
+	return now.Add(time.Duration(reset) * time.Second), true
+}
+
+func parseRateLimitHeaders(header http.Header, prefix string) (remaining, reset int64, ok bool) {
+	remaining, errRemaining := strconv.ParseInt(strings.TrimSpace(header.Get(prefix+"Remaining")), 10, 64)
+	reset, errReset := strconv.ParseInt(strings.TrimSpace(header.Get(prefix+"Reset")), 10, 64)
+	return remaining, reset, errRemaining == nil && errReset == nil
+}
+
+// parseRateLimitField parses the combined RateLimit field, e.g. "limit=100, remaining=0, reset=30".
+func parseRateLimitField(value string) (remaining, reset int64, ok bool) {
+	var hasRemaining, hasReset bool
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+		name, raw, _ := strings.Cut(strings.TrimSpace(item), "=")
+		n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(name) {
+		case "remaining", "r":
+			remaining, hasRemaining = n, true
+		case "reset", "t":
+			reset, hasReset = n, true
+		}
+	}
+	return remaining, reset, hasRemaining && hasReset
+}
+
+// tokenBucket is a token bucket rate limiter.
+// Waiting requests reserve tokens in advance, so the bucket can go negative.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit Limit, now time.Time) *tokenBucket {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &tokenBucket{
+		rate:   limit.Rate,
+		burst:  burst,
+		tokens: burst,
+		last:   now,
+	}
+}
+
+// reserve takes a token and returns the delay before it can be used.
+func (bucket *tokenBucket) reserve(now time.Time) time.Duration {
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
+	if elapsed := now.Sub(bucket.last); elapsed > 0 {
+		bucket.tokens += elapsed.Seconds() * bucket.rate
+		if bucket.tokens > bucket.burst {
+			bucket.tokens = bucket.burst
+		}
+		bucket.last = now
+	}
+
+	bucket.tokens--
+	if bucket.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-bucket.tokens / bucket.rate * float64(time.Second))
+}
+
+// cancel returns a reserved token.
+func (bucket *tokenBucket) cancel() {
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
+	bucket.tokens++
+	if bucket.tokens > bucket.burst {
+		bucket.tokens = bucket.burst
+	}
+}
+
+func (bucket *tokenBucket) wait(ctx context.Context) error {
+	delay := bucket.reserve(time.Now())
+	if delay <= 0 {
+		return nil
+	}
+
+	if err := sleep(ctx, delay); err != nil {
+		bucket.cancel()
+		return err
+	}
+	return nil
+}
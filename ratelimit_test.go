@@ -0,0 +1,173 @@
+package httpclient_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ninedraft/httpclient"
+)
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	tc := func(name string, opts httpclient.RateLimitOptions) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			defer server.Assert(t)
+
+			client := httpclient.NewFrom(server.Client())
+			client.Use(httpclient.RateLimit(opts))
+
+			start := time.Now()
+			for i := 0; i < 4; i++ {
+				resp, err := client.Get(context.Background(), server.URL)
+				requireEqual(t, nil, err, "call %d error", i)
+				resp.Body.Close()
+			}
+
+			// burst of 2 requests, then 2 requests with 50ms interval
+			elapsed := time.Since(start)
+			assertEqual(t, true, elapsed >= 90*time.Millisecond, "requests must be throttled, elapsed %s", elapsed)
+		})
+	}
+
+	limit := httpclient.Limit{Rate: 20, Burst: 2}
+
+	tc("global", httpclient.RateLimitOptions{Global: limit})
+	tc("per host", httpclient.RateLimitOptions{PerHost: limit})
+	tc("host override", httpclient.RateLimitOptions{
+		Hosts: map[string]httpclient.Limit{"127.0.0.1": limit},
+	})
+}
+
+func TestRateLimit_ContextDeadline(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.RateLimit(httpclient.RateLimitOptions{
+		Global: httpclient.Limit{Rate: 0.1},
+	}))
+
+	resp, errFirst := client.Get(context.Background(), server.URL)
+	requireEqual(t, nil, errFirst, "first call error")
+	resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, errSecond := client.Get(ctx, server.URL)
+	assertEqual(t, context.DeadlineExceeded, errSecond, "second call error")
+}
+
+func TestRateLimit_HostWaitError(t *testing.T) {
+	t.Parallel()
+
+	doer := httpclient.RateLimit(httpclient.RateLimitOptions{
+		Global: httpclient.Limit{Rate: 0.1, Burst: 2},
+		Hosts:  map[string]httpclient.Limit{"slow.example": {Rate: 0.1}},
+	})(httpclient.DoerFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+
+	call := func(addr string, body io.ReadCloser) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		req, errReq := http.NewRequestWithContext(ctx, http.MethodPost, addr, body)
+		requireEqual(t, nil, errReq, "request error")
+
+		resp, err := doer.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	requireEqual(t, nil, call("http://slow.example", http.NoBody), "first call error")
+
+	body := &closeTracker{Reader: strings.NewReader("body")}
+	errSlow := call("http://slow.example", body)
+	assertEqual(t, context.DeadlineExceeded, errSlow, "host wait error")
+	assertEqual(t, true, body.closed.Load(), "body of a throttled request is closed")
+
+	// the global token of the failed request is returned
+	assertEqual(t, nil, call("http://fast.example", http.NoBody), "other host call error")
+}
+
+func TestRateLimit_Adaptive(t *testing.T) {
+	t.Parallel()
+
+	tc := func(name string, header http.Header, status int) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				for key, values := range header {
+					w.Header()[key] = values
+				}
+				w.WriteHeader(status)
+			})
+			defer server.Assert(t)
+
+			client := httpclient.NewFrom(server.Client())
+			client.Use(httpclient.RateLimit(httpclient.RateLimitOptions{Adaptive: true}))
+
+			resp, errFirst := client.Get(context.Background(), server.URL)
+			requireEqual(t, nil, errFirst, "first call error")
+			resp.Body.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			_, errSecond := client.Get(ctx, server.URL)
+			assertEqual(t, context.DeadlineExceeded, errSecond, "second call error")
+		})
+	}
+
+	tc("RateLimit", http.Header{"Ratelimit": {"limit=10, remaining=0, reset=60"}}, http.StatusOK)
+	tc("RateLimit-Reset", http.Header{
+		"Ratelimit-Remaining": {"0"},
+		"Ratelimit-Reset":     {"60"},
+	}, http.StatusOK)
+	tc("X-RateLimit-Reset epoch", http.Header{
+		"X-Ratelimit-Remaining": {"0"},
+		"X-Ratelimit-Reset":     {strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)},
+	}, http.StatusOK)
+	tc("Retry-After", http.Header{"Retry-After": {"60"}}, http.StatusTooManyRequests)
+}
+
+func TestRateLimit_AdaptiveRemaining(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "5")
+		w.Header().Set("X-RateLimit-Reset", "60")
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.RateLimit(httpclient.RateLimitOptions{Adaptive: true}))
+
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		resp, err := client.Get(ctx, server.URL)
+		requireEqual(t, nil, err, "call %d error", i)
+		resp.Body.Close()
+		cancel()
+	}
+}
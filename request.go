@@ -0,0 +1,221 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Request is a builder of a single request.
+// It is created by Client.NewRequest and executed by one of the terminal methods: Do, Decode, DecodeJSON or DecodeXML.
+// Errors of the builder methods, like encoding errors, are returned by the terminal methods.
+// A Request must not be reused after it is executed, because its body may be consumed.
+type Request struct {
+	client *Client
+	method string
+	addr   string
+	opts   []RequestOption
+	err    error
+
+	body      io.Reader
+	bodyType  string
+	form      url.Values
+	multipart WriteMultipart
+}
+
+// NewRequest creates a request builder for the given method and address.
+// The address is resolved the same way as in Client.Get.
+func (client *Client) NewRequest(method, addr string) *Request {
+	return &Request{
+		client: client,
+		method: method,
+		addr:   addr,
+	}
+}
+
+// Apply adds the request options.
+func (r *Request) Apply(opts ...RequestOption) *Request {
+	r.opts = append(r.opts, opts...)
+	return r
+}
+
+// Header adds the value to the request header.
+// Request headers replace the client default headers with the same keys.
+func (r *Request) Header(key, value string) *Request {
+	return r.Apply(WithHeader(key, value))
+}
+
+// Query adds the value to the query parameter.
+// Query parameters replace the address query parameters with the same keys.
+func (r *Request) Query(key, value string) *Request {
+	return r.Apply(WithQuery(key, value))
+}
+
+// PathParam sets the value of the "{name}" placeholder in the address path.
+func (r *Request) PathParam(name, value string) *Request {
+	return r.Apply(PathParam(name, value))
+}
+
+// Timeout limits the time of the request, including reading the response body.
+func (r *Request) Timeout(timeout time.Duration) *Request {
+	return r.Apply(WithTimeout(timeout))
+}
+
+// Expect sets the accepted status codes, overriding Client.AcceptStatus.
+// Responses with other status codes are closed and reported as *StatusError.
+func (r *Request) Expect(codes ...int) *Request {
+	ranges := make([]StatusRange, 0, len(codes))
+	for _, code := range codes {
+		ranges = append(ranges, StatusCode(code))
+	}
+	return r.Apply(withExpect(ranges...))
+}
+
+// Body sets the request body with the given content type.
+// An empty content type sends no Content-Type header, unless it is set by Client.Header or WithHeader.
+func (r *Request) Body(bodyType string, body io.Reader) *Request {
+	r.resetBody()
+	r.body, r.bodyType = body, bodyType
+	return r
+}
+
+// Encode sets the request body encoded by the codec.
+// The Content-Type header is the media type of the codec.
+func (r *Request) Encode(codec Codec, obj any) *Request {
+	data, errEncode := codec.Marshal(obj)
+	if errEncode != nil {
+		r.err = errEncode
+		return r
+	}
+	return r.Body(codec.MediaType(), bytes.NewReader(data))
+}
+
+// JSON sets the JSON-encoded request body.
+func (r *Request) JSON(obj any) *Request {
+	return r.Encode(JSONCodec{}, obj)
+}
+
+// XML sets the XML-encoded request body. The body has no XML declaration.
+func (r *Request) XML(obj any) *Request {
+	return r.Encode(XMLCodec{}, obj)
+}
+
+// Form sets the form data.
+// For GET and HEAD requests the form is encoded as URL query parameters,
+// which replace the address query parameters with the same keys and are replaced by the WithQuery ones.
+// For other methods the form is encoded as "application/x-www-form-urlencoded" body.
+func (r *Request) Form(data url.Values) *Request {
+	if r.method == http.MethodGet || r.method == http.MethodHead {
+		r.resetBody()
+		r.form = data
+		return r
+	}
+	return r.Body(encodingURL, strings.NewReader(data.Encode()))
+}
+
+// Multipart sets the multipart request body.
+// See Client.MultipartMaxMemory for the body modes.
+func (r *Request) Multipart(writeMultipart WriteMultipart) *Request {
+	r.resetBody()
+	r.multipart = writeMultipart
+	return r
+}
+
+func (r *Request) resetBody() {
+	r.body, r.bodyType, r.form, r.multipart = nil, "", nil, nil
+}
+
+// Do executes the request.
+func (r *Request) Do(ctx context.Context) (*http.Response, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	opts := r.opts
+	if r.form != nil {
+		opts = append(opts[:len(opts):len(opts)], withFormQuery(r.form))
+	}
+	options := newRequestOptions(opts)
+
+	if options.timeout <= 0 {
+		return r.do(ctx, options)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, options.timeout)
+
+	resp, err := r.do(ctx, options)
+	if err != nil || resp == nil {
+		cancel()
+		return resp, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+
+	return resp, nil
+}
+
+func (r *Request) do(ctx context.Context, options *requestOptions) (*http.Response, error) {
+	client := r.client
+
+	if r.multipart != nil {
+		return client.doMultipart(ctx, r.method, r.addr, r.multipart, options)
+	}
+
+	req, err := client.newRequest(ctx, r.method, r.addr, r.body, options)
+	if err != nil {
+		return nil, err
+	}
+	if r.bodyType != "" {
+		req.Header.Set(headerContentType, r.bodyType)
+	}
+
+	return client.do(req, options)
+}
+
+// Decode executes the request and decodes the response into dst
+// with the codec of the response Content-Type. It behaves like Client.Decode,
+// except that the status codes accepted by Expect or Client.AcceptStatus are decoded too.
+func (r *Request) Decode(ctx context.Context, dst any) error {
+	resp, errDo := r.Do(ctx)
+	return decodeBody(resp, errDo, dst, r.client.codec, r.accepts)
+}
+
+// DecodeJSON executes the request and decodes the JSON response into dst.
+// It behaves like the DecodeJSON function,
+// except that the status codes accepted by Expect or Client.AcceptStatus are decoded too.
+func (r *Request) DecodeJSON(ctx context.Context, dst any) error {
+	resp, errDo := r.Do(ctx)
+	return decodeBody(resp, errDo, dst, withCodec(JSONCodec{}), r.accepts)
+}
+
+// DecodeXML executes the request and decodes the XML response into dst.
+// It behaves like the DecodeXML function,
+// except that the status codes accepted by Expect or Client.AcceptStatus are decoded too.
+func (r *Request) DecodeXML(ctx context.Context, dst any) error {
+	resp, errDo := r.Do(ctx)
+	return decodeBody(resp, errDo, dst, withCodec(XMLCodec{}), r.accepts)
+}
+
+// accepts reports whether the response status code is accepted by the request.
+// Without Expect and Client.AcceptStatus only 2xx status codes are accepted.
+func (r *Request) accepts(statusCode int) bool {
+	accept := r.client.acceptedStatus(newRequestOptions(r.opts))
+	if len(accept) == 0 {
+		return isSuccess(statusCode)
+	}
+	return acceptStatus(accept, statusCode)
+}
+
+// cancelBody releases the request context when the response body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body *cancelBody) Close() error {
+	defer body.cancel()
+	return body.ReadCloser.Close()
+}
@@ -0,0 +1,312 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ninedraft/httpclient"
+)
+
+func TestRequest(t *testing.T) {
+	t.Parallel()
+
+	type payload struct {
+		Foo string `json:"foo"`
+	}
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, http.MethodPost, r.Method, "http method")
+		assertEqual(t, "/users/42", r.URL.Path, "path")
+		assertEqual(t, "1", r.URL.Query().Get("page"), "query")
+		assertEqual(t, "request", r.Header.Get("X-Source"), "request header must override client header")
+		assertEqual(t, "client", r.Header.Get("X-Client"), "client header")
+		assertEqual(t, "application/json", r.Header.Get("Content-Type"), "content type")
+		assertEqual(t, `{"foo":"bar"}`, readString(t, r.Body), "body")
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"foo": "baz"}`)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Header.Set("X-Source", "client")
+	client.Header.Set("X-Client", "client")
+
+	got := payload{}
+	errCall := client.NewRequest(http.MethodPost, server.URL+"/users/{id}").
+		PathParam("id", "42").
+		Query("page", "1").
+		Header("X-Source", "request").
+		JSON(payload{Foo: "bar"}).
+		DecodeJSON(context.Background(), &got)
+
+	requireEqual(t, nil, errCall, "call error")
+	assertEqual(t, payload{Foo: "baz"}, got, "response")
+}
+
+func TestRequest_Form(t *testing.T) {
+	t.Parallel()
+
+	form := url.Values{"foo": {"bar"}}
+
+	tc := func(method string) {
+		t.Run(method, func(t *testing.T) {
+			t.Parallel()
+
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				errForm := r.ParseForm()
+				requireEqual(t, nil, errForm, "parse form error")
+				assertEqualSlices(t, form["foo"], r.Form["foo"], "form value")
+				w.WriteHeader(http.StatusOK)
+			})
+			defer server.Assert(t)
+
+			client := httpclient.NewFrom(server.Client())
+
+			resp, errCall := client.NewRequest(method, server.URL).Form(form).Do(context.Background())
+
+			requireEqual(t, nil, errCall, "call error")
+			defer resp.Body.Close()
+			assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+		})
+	}
+
+	tc(http.MethodGet)
+	tc(http.MethodPost)
+	tc(http.MethodPut)
+}
+
+func TestRequest_Multipart(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		errParse := r.ParseMultipartForm(1 << 20)
+		requireEqual(t, nil, errParse, "parse multipart form error")
+		assertEqual(t, "value", r.MultipartForm.Value["field"][0], "field value")
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+
+	resp, errCall := client.NewRequest(http.MethodPost, server.URL).
+		Multipart(httpclient.MultipartFields(url.Values{"field": {"value"}})).
+		Do(context.Background())
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+	assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+}
+
+func TestRequest_Expect(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.AcceptStatus = []httpclient.StatusRange{httpclient.Status2xx}
+
+	resp, errCall := client.NewRequest(http.MethodGet, server.URL).
+		Expect(http.StatusOK).
+		Do(context.Background())
+
+	assertEqual(t, nil, resp, "response")
+
+	var statusErr *httpclient.StatusError
+	requireEqual(t, true, errors.As(errCall, &statusErr), "expected status error, got %v", errCall)
+	assertEqual(t, http.StatusAccepted, statusErr.StatusCode, "status code")
+}
+
+func TestRequest_Timeout(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	defer server.Assert(t)
+	defer close(release)
+
+	client := httpclient.NewFrom(server.Client())
+
+	_, errCall := client.NewRequest(http.MethodGet, server.URL).
+		Timeout(10 * time.Millisecond).
+		Do(context.Background())
+
+	assertEqual(t, true, errors.Is(errCall, context.DeadlineExceeded), "expected deadline error, got %v", errCall)
+}
+
+func TestRequest_TimeoutBody(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "hello")
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+
+	resp, errCall := client.NewRequest(http.MethodGet, server.URL).
+		Timeout(time.Second).
+		Do(context.Background())
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+
+	// the body is readable until it is closed
+	assertEqual(t, "hello", readString(t, resp.Body), "body")
+}
+
+func TestRequest_JSONError(t *testing.T) {
+	t.Parallel()
+
+	client := httpclient.NewFrom(httpclient.DoerFunc(func(*http.Request) (*http.Response, error) {
+		t.Error("doer must not be called")
+		return nil, nil
+	}))
+
+	_, errCall := client.NewRequest(http.MethodPost, "http://example.com").
+		JSON(make(chan int)).
+		Do(context.Background())
+
+	assertNotEqual(t, nil, errCall, "call error")
+}
+
+func TestRequest_XML(t *testing.T) {
+	t.Parallel()
+
+	type payload struct {
+		XMLName struct{} `xml:"payload"`
+		Foo     string   `xml:"foo"`
+	}
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, "application/xml", r.Header.Get("Content-Type"), "content type")
+		assertEqual(t, `<payload><foo>bar</foo></payload>`, readString(t, r.Body), "body")
+
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = io.WriteString(w, `<payload><foo>baz</foo></payload>`)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+
+	got := payload{}
+	errCall := client.NewRequest(http.MethodPost, server.URL).
+		XML(payload{Foo: "bar"}).
+		DecodeXML(context.Background(), &got)
+
+	requireEqual(t, nil, errCall, "call error")
+	assertEqual(t, "baz", got.Foo, "response")
+}
+
+func TestRequest_XMLError(t *testing.T) {
+	t.Parallel()
+
+	client := httpclient.NewFrom(httpclient.DoerFunc(func(*http.Request) (*http.Response, error) {
+		t.Error("doer must not be called")
+		return nil, nil
+	}))
+
+	_, errCall := client.NewRequest(http.MethodPost, "http://example.com").
+		XML(make(chan int)).
+		Do(context.Background())
+
+	assertNotEqual(t, nil, errCall, "call error")
+}
+
+func TestRequest_Body(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, "text/plain", r.Header.Get("Content-Type"), "content type")
+		assertEqual(t, "hello", readString(t, r.Body), "body")
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+
+	resp, errCall := client.NewRequest(MethodQuery, server.URL).
+		Body("text/plain", strings.NewReader("hello")).
+		Do(context.Background())
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+}
+
+func TestRequest_EmptyBodyType(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, 0, len(r.Header.Values("Content-Type")), "content type headers")
+		assertEqual(t, "hello", readString(t, r.Body), "body")
+		w.WriteHeader(http.StatusOK)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+
+	resp, errCall := client.Post(context.Background(), server.URL, "", strings.NewReader("hello"))
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+}
+
+func TestRequest_DecodeExpect(t *testing.T) {
+	t.Parallel()
+
+	type problem struct {
+		Title string `json:"title" xml:"title"`
+	}
+
+	bodies := map[string]string{
+		"application/json": `{"title": "not found"}`,
+		"application/xml":  `<problem><title>not found</title></problem>`,
+	}
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		accept := r.Header.Get("Accept")
+		w.Header().Set("Content-Type", accept)
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, bodies[accept])
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+
+	decoders := map[string]func(r *httpclient.Request, dst any) error{
+		"decode": func(r *httpclient.Request, dst any) error {
+			return r.Header("Accept", "application/json").Decode(context.Background(), dst)
+		},
+		"json": func(r *httpclient.Request, dst any) error {
+			return r.Header("Accept", "application/json").DecodeJSON(context.Background(), dst)
+		},
+		"xml": func(r *httpclient.Request, dst any) error {
+			return r.Header("Accept", "application/xml").DecodeXML(context.Background(), dst)
+		},
+	}
+
+	for name, decode := range decoders {
+		var got problem
+		errExpected := decode(client.NewRequest(http.MethodGet, server.URL).Expect(http.StatusNotFound), &got)
+		requireEqual(t, nil, errExpected, "%s: expected status error", name)
+		assertEqual(t, "not found", got.Title, "%s: decoded body", name)
+
+		errOther := decode(client.NewRequest(http.MethodGet, server.URL), &problem{})
+		var statusErr *httpclient.StatusError
+		assertEqual(t, true, errors.As(errOther, &statusErr), "%s: expected status error, got %v", name, errOther)
+	}
+}
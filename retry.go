@@ -0,0 +1,253 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryOptions configures the Retry wrapper.
+// Zero values are replaced with defaults.
+type RetryOptions struct {
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	// Default is 3.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry.
+	// Each next retry doubles it. Default is 100ms.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the exponential backoff. Default is 10s.
+	// It does not limit delays requested by the Retry-After header.
+	MaxDelay time.Duration
+
+	// MaxRetryAfter caps delays requested by the Retry-After header. Default is 1m.
+	MaxRetryAfter time.Duration
+
+	// RetryNonIdempotent enables retries of POST and PATCH requests.
+	RetryNonIdempotent bool
+
+	// ShouldRetry reports whether the attempt result should be retried.
+	// Default is IsRetryable.
+	ShouldRetry func(resp *http.Response, err error) bool
+}
+
+func (opts RetryOptions) withDefaults() RetryOptions {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = 100 * time.Millisecond
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 10 * time.Second
+	}
+	if opts.MaxRetryAfter <= 0 {
+		opts.MaxRetryAfter = time.Minute
+	}
+	if opts.ShouldRetry == nil {
+		opts.ShouldRetry = IsRetryable
+	}
+	return opts
+}
+
+// IsRetryable reports whether the request can succeed if it is retried.
+// It is true for transport errors, except context cancellation,
+// and for 429, 502, 503 and 504 status codes.
+func IsRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// Retry creates a wrapper which retries failed requests
+// with exponential backoff and full jitter.
+//
+// Idempotent requests (GET, HEAD, OPTIONS, PUT, DELETE, QUERY) are retried by default,
+// POST and PATCH requests only if RetryOptions.RetryNonIdempotent is set.
+// Request bodies are rewound using http.Request.GetBody,
+// requests with bodies which can't be rewound are not retried.
+//
+// The Retry-After response header overrides the backoff delay.
+// If the next attempt can't start before the request context deadline,
+// the last result is returned.
+func Retry(opts RetryOptions) Wrapper {
+	opts = opts.withDefaults()
+
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return opts.do(next, req)
+		})
+	}
+}
+
+func (opts RetryOptions) do(next Doer, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	canRetry := opts.canRetry(req)
+
+	for attempt := 1; ; attempt++ {
+		attemptReq, errRewind := retryRequest(req, attempt)
+		if errRewind != nil {
+			return nil, errRewind
+		}
+
+		resp, err := next.Do(attemptReq)
+		if !canRetry || attempt >= opts.MaxAttempts || !opts.ShouldRetry(resp, err) {
+			return resp, err
+		}
+
+		delay := opts.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header, time.Now()); ok {
+				delay = min(retryAfter, opts.MaxRetryAfter)
+			}
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return resp, err
+		}
+
+		if resp != nil {
+			drainAndClose(resp.Body)
+		}
+
+		if errWait := sleep(ctx, delay); errWait != nil {
+			return nil, errWait
+		}
+	}
+}
+
+func (opts RetryOptions) canRetry(req *http.Request) bool {
+	if !hasBody(req) || req.GetBody != nil {
+		return isIdempotent(req.Method) || opts.RetryNonIdempotent
+	}
+	return false
+}
+
+// backoff returns a random delay in [0, min(MaxDelay, BaseDelay * 2^(attempt-1))).
+func (opts RetryOptions) backoff(attempt int) time.Duration {
+	limit := opts.MaxDelay
+	if shift := attempt - 1; shift < 32 {
+		if delay := opts.BaseDelay << shift; delay > 0 && delay < limit {
+			limit = delay
+		}
+	}
+
+	return time.Duration(rand.Int63n(int64(limit)))
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete, methodQuery:
+		return true
+	default:
+		return false
+	}
+}
+
+func hasBody(req *http.Request) bool {
+	return req.Body != nil && req.Body != http.NoBody
+}
+
+// ErrBodyNotRewindable is returned when a request has to be sent again,
+// but its body can't be rewound because http.Request.GetBody is not set.
+var ErrBodyNotRewindable = errors.New("request body can't be rewound")
+
+// rewindBody returns a fresh copy of the request body.
+func rewindBody(req *http.Request) (io.ReadCloser, error) {
+	if !hasBody(req) {
+		return req.Body, nil
+	}
+	if req.GetBody == nil {
+		return nil, ErrBodyNotRewindable
+	}
+	return req.GetBody()
+}
+
+// retryRequest returns a copy of the request for the given attempt.
+// The first attempt reuses the original body, next ones rewind it.
+func retryRequest(req *http.Request, attempt int) (*http.Request, error) {
+	attemptReq := req.Clone(withAttempt(req.Context(), attempt))
+	if attempt == 1 {
+		return attemptReq, nil
+	}
+
+	body, errRewind := rewindBody(req)
+	if errRewind != nil {
+		return nil, errRewind
+	}
+	attemptReq.Body = body
+
+	return attemptReq, nil
+}
+
+// maxRetryAfterSeconds is the longest delay-seconds value which fits time.Duration.
+const maxRetryAfterSeconds = int64(math.MaxInt64 / time.Second)
+
+// parseRetryAfter parses the Retry-After header in both delay-seconds and HTTP-date forms.
+// Delays which don't fit time.Duration are clamped.
+func parseRetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(min(seconds, maxRetryAfterSeconds)) * time.Second, true
+	}
+
+	date, errDate := http.ParseTime(value)
+	if errDate != nil {
+		return 0, false
+	}
+
+	if delay := date.Sub(now); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type attemptKey struct{}
+
+func withAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// RetryAttempt returns the number of the current attempt, starting from 1.
+// It returns 0 if the request is not executed by the Retry wrapper.
+func RetryAttempt(ctx context.Context) int {
+	attempt, _ := ctx.Value(attemptKey{}).(int)
+	return attempt
+}
@@ -0,0 +1,226 @@
+package httpclient_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ninedraft/httpclient"
+)
+
+var fastRetry = httpclient.RetryOptions{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    5 * time.Millisecond,
+}
+
+// flakyServer responds with the given status code to the first failures requests.
+func flakyServer(t *testing.T, failures int32, status int, header http.Header) (*serverAssert, *atomic.Int32) {
+	calls := &atomic.Int32{}
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body := readString(t, r.Body)
+
+		if calls.Add(1) <= failures {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(status)
+			return
+		}
+
+		_, _ = io.WriteString(w, body)
+	})
+
+	return server, calls
+}
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+
+	server, calls := flakyServer(t, 2, http.StatusServiceUnavailable, nil)
+	defer server.Assert(t)
+
+	var attempts []int
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Retry(fastRetry), func(next httpclient.Doer) httpclient.Doer {
+		return httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+			attempts = append(attempts, httpclient.RetryAttempt(req.Context()))
+			return next.Do(req)
+		})
+	})
+
+	resp, errCall := client.PutJSON(context.Background(), server.URL, "hello")
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+
+	assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+	assertEqual(t, `"hello"`, readString(t, resp.Body), "rewound body")
+	assertEqual(t, 3, calls.Load(), "calls")
+	assertEqualSlices(t, []int{1, 2, 3}, attempts, "attempts")
+}
+
+func TestRetry_MaxAttempts(t *testing.T) {
+	t.Parallel()
+
+	server, calls := flakyServer(t, 10, http.StatusBadGateway, nil)
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Retry(fastRetry))
+
+	resp, errCall := client.Get(context.Background(), server.URL)
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+
+	assertEqual(t, http.StatusBadGateway, resp.StatusCode, "status code")
+	assertEqual(t, 3, calls.Load(), "calls")
+}
+
+func TestRetry_NonIdempotent(t *testing.T) {
+	t.Parallel()
+
+	tc := func(name string, opts httpclient.RetryOptions, wantCalls int32) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server, calls := flakyServer(t, 1, http.StatusServiceUnavailable, nil)
+			defer server.Assert(t)
+
+			client := httpclient.NewFrom(server.Client())
+			client.Use(httpclient.Retry(opts))
+
+			resp, errCall := client.Post(context.Background(), server.URL, "text/plain", strings.NewReader("hello"))
+
+			requireEqual(t, nil, errCall, "call error")
+			defer resp.Body.Close()
+
+			assertEqual(t, wantCalls, calls.Load(), "calls")
+		})
+	}
+
+	optIn := fastRetry
+	optIn.RetryNonIdempotent = true
+
+	tc("default", fastRetry, 1)
+	tc("opt-in", optIn, 2)
+}
+
+func TestRetry_NotRewindable(t *testing.T) {
+	t.Parallel()
+
+	server, calls := flakyServer(t, 1, http.StatusServiceUnavailable, nil)
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Retry(fastRetry))
+
+	body := io.MultiReader(strings.NewReader("hello"))
+	resp, errCall := client.Put(context.Background(), server.URL, "text/plain", body)
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+
+	assertEqual(t, http.StatusServiceUnavailable, resp.StatusCode, "status code")
+	assertEqual(t, 1, calls.Load(), "calls")
+}
+
+func TestRetry_RetryAfterDate(t *testing.T) {
+	t.Parallel()
+
+	header := http.Header{
+		"Retry-After": {time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)},
+	}
+	server, calls := flakyServer(t, 1, http.StatusTooManyRequests, header)
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Retry(httpclient.RetryOptions{BaseDelay: time.Hour}))
+
+	resp, errCall := client.Get(context.Background(), server.URL)
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+
+	assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+	assertEqual(t, 2, calls.Load(), "calls")
+}
+
+func TestRetry_RetryAfterDeadline(t *testing.T) {
+	t.Parallel()
+
+	header := http.Header{"Retry-After": {"120"}}
+	server, calls := flakyServer(t, 1, http.StatusServiceUnavailable, header)
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Retry(fastRetry))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	start := time.Now()
+	resp, errCall := client.Get(ctx, server.URL)
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+
+	assertEqual(t, http.StatusServiceUnavailable, resp.StatusCode, "status code")
+	assertEqual(t, "120", resp.Header.Get("Retry-After"), "retry-after")
+	assertEqual(t, 1, calls.Load(), "calls")
+	assertEqual(t, true, time.Since(start) < time.Minute, "must not wait for retry")
+}
+
+func TestRetry_MaxRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	tc := func(name, retryAfter string) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server, calls := flakyServer(t, 1, http.StatusServiceUnavailable, http.Header{"Retry-After": {retryAfter}})
+			defer server.Assert(t)
+
+			client := httpclient.NewFrom(server.Client())
+			client.Use(httpclient.Retry(httpclient.RetryOptions{MaxRetryAfter: 10 * time.Millisecond}))
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			resp, errCall := client.Get(ctx, server.URL)
+
+			requireEqual(t, nil, errCall, "call error")
+			defer resp.Body.Close()
+
+			assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+			assertEqual(t, 2, calls.Load(), "calls")
+		})
+	}
+
+	tc("hour", "3600")
+	tc("overflow", "9223372036854775807")
+	tc("out of duration range", "10000000000000")
+}
+
+func TestRetry_ContextCanceled(t *testing.T) {
+	t.Parallel()
+
+	server, _ := flakyServer(t, 10, http.StatusServiceUnavailable, nil)
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Retry(httpclient.RetryOptions{BaseDelay: time.Hour, MaxDelay: time.Hour}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	_, errCall := client.Get(ctx, server.URL)
+
+	assertEqual(t, context.Canceled, errCall, "call error")
+}
@@ -0,0 +1,384 @@
+// Package sigv4 signs requests with AWS Signature Version 4.
+//
+// It works with AWS services and S3-compatible object stores.
+// A signer can be attached to a client with Client.Middleware:
+//
+//	signer := &sigv4.Signer{
+//		Credentials: sigv4.Credentials{AccessKeyID: "...", SecretAccessKey: "..."},
+//		Region:      "us-east-1",
+//		Service:     "s3",
+//	}
+//	client.Middleware = signer.Middleware()
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	algorithm  = "AWS4-HMAC-SHA256"
+	timeFormat = "20060102T150405Z"
+	dateFormat = "20060102"
+
+	// UnsignedPayload is the payload hash of requests whose bodies are not signed.
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+	// MaxPresignExpiry is the longest lifetime of a presigned URL.
+	MaxPresignExpiry = 7 * 24 * time.Hour
+)
+
+// emptyPayloadHash is the SHA-256 hash of an empty payload.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+var errNoCredentials = errors.New("sigv4: credentials are not set")
+
+// Credentials are AWS security credentials.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials.
+	SessionToken string
+}
+
+// Signer signs requests with AWS Signature Version 4.
+type Signer struct {
+	Credentials Credentials
+	Region      string
+	Service     string
+
+	// Now returns the signing time. Default is time.Now.
+	Now func() time.Time
+
+	// UnsignedPayload disables hashing of request bodies.
+	// Bodies which can't be rewound are never hashed, they are signed as UNSIGNED-PAYLOAD.
+	UnsignedPayload bool
+}
+
+// Middleware returns a function for Client.Middleware, which signs outgoing requests.
+//
+// The middleware signs a request once, before the client wrappers.
+// To sign every retry attempt again, add it as the last wrapper instead:
+//
+//	client.Use(httpclient.RequestMiddleware(signer.Middleware()))
+func (signer *Signer) Middleware() func(req *http.Request) (*http.Request, error) {
+	return func(req *http.Request) (*http.Request, error) {
+		req = req.Clone(req.Context())
+		if errSign := signer.Sign(req); errSign != nil {
+			return nil, errSign
+		}
+		return req, nil
+	}
+}
+
+// Sign adds the Authorization, X-Amz-Date and, for temporary credentials,
+// X-Amz-Security-Token headers to the request.
+// The X-Amz-Content-Sha256 header is set for the s3 service,
+// a value provided by the caller is used as the payload hash.
+//
+// The request body is read using http.Request.GetBody to compute its hash.
+// The request URL path is escaped as AWS expects it.
+func (signer *Signer) Sign(req *http.Request) error {
+	if signer.Credentials.AccessKeyID == "" || signer.Credentials.SecretAccessKey == "" {
+		return errNoCredentials
+	}
+
+	now := signer.now()
+
+	payloadHash, errHash := signer.payloadHash(req)
+	if errHash != nil {
+		return errHash
+	}
+
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", now.Format(timeFormat))
+	if signer.Credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", signer.Credentials.SessionToken)
+	}
+	if signer.isS3() {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	escapePath(req.URL)
+	headers, canonicalHeaders := canonicalHeaders(req)
+	query := canonicalQuery(req.URL.Query())
+
+	signature := signer.signature(now, canonicalRequest(req, signer.canonicalURI(req.URL), query, canonicalHeaders, headers, payloadHash))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, signer.Credentials.AccessKeyID, signer.scope(now), headers, signature))
+
+	return nil
+}
+
+// Presign returns the request URL with the signature in the query parameters.
+// The URL can be used without credentials until it expires.
+// All the request headers are signed, so they must be sent with the presigned URL.
+// Payloads of the s3 service are not signed.
+func (signer *Signer) Presign(req *http.Request, expires time.Duration) (*url.URL, error) {
+	if expires <= 0 || expires > MaxPresignExpiry {
+		return nil, fmt.Errorf("sigv4: presign expiry %v is out of range (0, %v]", expires, MaxPresignExpiry)
+	}
+	if signer.Credentials.AccessKeyID == "" || signer.Credentials.SecretAccessKey == "" {
+		return nil, errNoCredentials
+	}
+
+	payloadHash := UnsignedPayload
+	if !signer.isS3() {
+		var errHash error
+		payloadHash, errHash = signer.payloadHash(req)
+		if errHash != nil {
+			return nil, errHash
+		}
+	}
+
+	now := signer.now()
+	presigned := *req
+	presigned.URL = cloneURL(req.URL)
+	presigned.Header = req.Header.Clone()
+	presigned.Header.Del("Authorization")
+	escapePath(presigned.URL)
+
+	headers, canonicalHeaders := canonicalHeaders(&presigned)
+
+	query := presigned.URL.Query()
+	query.Set("X-Amz-Algorithm", algorithm)
+	query.Set("X-Amz-Credential", signer.Credentials.AccessKeyID+"/"+signer.scope(now))
+	query.Set("X-Amz-Date", now.Format(timeFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", headers)
+	if signer.Credentials.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", signer.Credentials.SessionToken)
+	}
+
+	signature := signer.signature(now, canonicalRequest(&presigned, signer.canonicalURI(presigned.URL), canonicalQuery(query), canonicalHeaders, headers, payloadHash))
+	query.Set("X-Amz-Signature", signature)
+
+	presigned.URL.RawQuery = canonicalQuery(query)
+
+	return presigned.URL, nil
+}
+
+func (signer *Signer) now() time.Time {
+	if signer.Now != nil {
+		return signer.Now().UTC()
+	}
+	return time.Now().UTC()
+}
+
+func (signer *Signer) isS3() bool {
+	return signer.Service == "s3"
+}
+
+func (signer *Signer) scope(now time.Time) string {
+	return strings.Join([]string{now.Format(dateFormat), signer.Region, signer.Service, "aws4_request"}, "/")
+}
+
+func (signer *Signer) signature(now time.Time, canonicalRequest string) string {
+	stringToSign := strings.Join([]string{
+		algorithm,
+		now.Format(timeFormat),
+		signer.scope(now),
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+signer.Credentials.SecretAccessKey), now.Format(dateFormat))
+	key = hmacSHA256(key, signer.Region)
+	key = hmacSHA256(key, signer.Service)
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// payloadHash returns the hex encoded SHA-256 hash of the request body.
+func (signer *Signer) payloadHash(req *http.Request) (string, error) {
+	if value := req.Header.Get("X-Amz-Content-Sha256"); value != "" {
+		return value, nil
+	}
+
+	if req.Body == nil || req.Body == http.NoBody {
+		return emptyPayloadHash, nil
+	}
+	if signer.UnsignedPayload || req.GetBody == nil {
+		return UnsignedPayload, nil
+	}
+
+	body, errBody := req.GetBody()
+	if errBody != nil {
+		return "", fmt.Errorf("sigv4: payload hash: %w", errBody)
+	}
+	defer body.Close()
+
+	hasher := sha256.New()
+	if _, errRead := io.Copy(hasher, body); errRead != nil {
+		return "", fmt.Errorf("sigv4: payload hash: %w", errRead)
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// canonicalURI returns the escaped path of the URL.
+// Paths are normalized and escaped twice for all services except s3.
+func (signer *Signer) canonicalURI(u *url.URL) string {
+	escaped := u.EscapedPath()
+	if escaped == "" {
+		return "/"
+	}
+	if signer.isS3() {
+		return escaped
+	}
+	return escape(normalizePath(escaped), true)
+}
+
+// normalizePath removes dot segments and repeated slashes, keeping the trailing slash.
+func normalizePath(p string) string {
+	cleaned := path.Clean(p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// escapePath sets the raw path of the URL to the AWS escaping of its path,
+// so the signed path is the same as the sent one.
+func escapePath(u *url.URL) {
+	if u.Opaque != "" {
+		return
+	}
+	u.RawPath = escape(u.Path, true)
+}
+
+func canonicalRequest(req *http.Request, uri, query, canonicalHeaders, signedHeaders, payloadHash string) string {
+	return strings.Join([]string{
+		req.Method,
+		uri,
+		query,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+}
+
+// ignoredHeaders are not signed, because proxies and transports may change them.
+var ignoredHeaders = map[string]bool{
+	"authorization":     true,
+	"user-agent":        true,
+	"x-amzn-trace-id":   true,
+	"expect":            true,
+	"connection":        true,
+	"transfer-encoding": true,
+}
+
+// canonicalHeaders returns the signed header names and the canonical headers block.
+func canonicalHeaders(req *http.Request) (signed, canonical string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	values := map[string]string{"host": host}
+	for key, vv := range req.Header {
+		name := strings.ToLower(key)
+		if ignoredHeaders[name] || name == "host" {
+			continue
+		}
+
+		trimmed := make([]string, 0, len(vv))
+		for _, v := range vv {
+			trimmed = append(trimmed, strings.Join(strings.Fields(v), " "))
+		}
+		values[name] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	block := strings.Builder{}
+	for _, name := range names {
+		block.WriteString(name)
+		block.WriteByte(':')
+		block.WriteString(values[name])
+		block.WriteByte('\n')
+	}
+
+	return strings.Join(names, ";"), block.String()
+}
+
+// canonicalQuery encodes the query parameters sorted by the encoded key and then value.
+func canonicalQuery(query url.Values) string {
+	type pair struct{ key, value string }
+
+	pairs := make([]pair, 0, len(query))
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, pair{key: escape(key, false), value: escape(value, false)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].key != pairs[j].key {
+			return pairs[i].key < pairs[j].key
+		}
+		return pairs[i].value < pairs[j].value
+	})
+
+	encoded := make([]string, 0, len(pairs))
+	for _, p := range pairs {
+		encoded = append(encoded, p.key+"="+p.value)
+	}
+	return strings.Join(encoded, "&")
+}
+
+// escape percent-encodes all characters except the unreserved ones, as required by AWS.
+func escape(s string, keepSlash bool) string {
+	const upperHex = "0123456789ABCDEF"
+
+	b := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~',
+			c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(upperHex[c>>4])
+			b.WriteByte(upperHex[c&15])
+		}
+	}
+	return b.String()
+}
+
+func cloneURL(u *url.URL) *url.URL {
+	clone := *u
+	if u.User != nil {
+		user := *u.User
+		clone.User = &user
+	}
+	return &clone
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,135 @@
+package httpclient
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// spool is a write-once buffer for request bodies.
+// It keeps up to limit bytes in memory and moves the data to a temporary file beyond that.
+// The data can be read any number of times, the temporary file is removed
+// when the spool is closed and all its readers are closed.
+type spool struct {
+	limit int64
+	size  int64
+	mem   bytes.Buffer
+	file  *os.File
+
+	mu      sync.Mutex
+	readers int
+	closed  bool
+}
+
+func newSpool(limit int64) *spool {
+	return &spool{limit: limit}
+}
+
+func (sp *spool) Write(p []byte) (int, error) {
+	if sp.file == nil && sp.size+int64(len(p)) > sp.limit {
+		if err := sp.spill(); err != nil {
+			return 0, err
+		}
+	}
+
+	var n int
+	var err error
+	if sp.file != nil {
+		n, err = sp.file.Write(p)
+	} else {
+		n, err = sp.mem.Write(p)
+	}
+	sp.size += int64(n)
+
+	return n, err
+}
+
+// spill moves the buffered data to a temporary file.
+func (sp *spool) spill() error {
+	file, errCreate := os.CreateTemp("", "httpclient-spool-*")
+	if errCreate != nil {
+		return errCreate
+	}
+	sp.file = file
+
+	if _, err := sp.mem.WriteTo(file); err != nil {
+		return err
+	}
+	sp.mem = bytes.Buffer{}
+
+	return nil
+}
+
+// Len returns the number of written bytes.
+func (sp *spool) Len() int64 {
+	return sp.size
+}
+
+// Open returns a new reader of the written data.
+func (sp *spool) Open() (io.ReadCloser, error) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if sp.closed {
+		return nil, errors.New("spool is closed")
+	}
+	sp.readers++
+
+	reader := &spoolReader{spool: sp}
+	if sp.file != nil {
+		reader.Reader = io.NewSectionReader(sp.file, 0, sp.size)
+	} else {
+		reader.Reader = bytes.NewReader(sp.mem.Bytes())
+	}
+
+	return reader, nil
+}
+
+// Close releases the spool. The temporary file is removed after all the readers are closed.
+func (sp *spool) Close() error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if sp.closed {
+		return nil
+	}
+	sp.closed = true
+
+	return sp.release()
+}
+
+func (sp *spool) closeReader() error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	sp.readers--
+
+	return sp.release()
+}
+
+func (sp *spool) release() error {
+	if !sp.closed || sp.readers > 0 || sp.file == nil {
+		return nil
+	}
+
+	file := sp.file
+	sp.file = nil
+
+	return errors.Join(file.Close(), os.Remove(file.Name()))
+}
+
+type spoolReader struct {
+	io.Reader
+	spool *spool
+	once  sync.Once
+}
+
+func (reader *spoolReader) Close() error {
+	var err error
+	reader.once.Do(func() {
+		err = reader.spool.closeReader()
+	})
+	return err
+}
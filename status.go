@@ -0,0 +1,138 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+)
+
+// StatusRange is an inclusive range of HTTP status codes.
+type StatusRange struct {
+	Min, Max int
+}
+
+// Status2xx matches all successful status codes.
+var Status2xx = StatusRange{Min: 200, Max: 299}
+
+// StatusCode returns a range matching a single status code.
+func StatusCode(code int) StatusRange {
+	return StatusRange{Min: code, Max: code}
+}
+
+// Contains reports whether the given status code is in the range.
+func (r StatusRange) Contains(code int) bool {
+	return r.Min <= code && code <= r.Max
+}
+
+func acceptStatus(ranges []StatusRange, code int) bool {
+	for _, r := range ranges {
+		if r.Contains(code) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxErrorBody limits the size of the response body snapshot stored in StatusError.
+const MaxErrorBody = 4 << 10
+
+// statusErrorHeaders are the response headers copied to StatusError.
+var statusErrorHeaders = []string{
+	"Content-Type",
+	"Date",
+	"Location",
+	"Retry-After",
+	"Www-Authenticate",
+	"X-Request-Id",
+}
+
+// StatusError is returned when a response has an unexpected status code.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+
+	// Header contains a subset of the response headers useful for diagnostics,
+	// such as Content-Type and Retry-After.
+	Header http.Header
+
+	// Body is a snapshot of the response body, at most MaxErrorBody bytes long.
+	Body []byte
+
+	// Problem is the decoded body of "application/problem+json" responses.
+	// It is nil for other media types.
+	Problem *Problem
+}
+
+// newStatusError reads a body snapshot from the response.
+// The response body is not closed.
+func newStatusError(resp *http.Response) *StatusError {
+	err := &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     make(http.Header),
+	}
+
+	if resp.Request != nil {
+		err.Method = resp.Request.Method
+		if resp.Request.URL != nil {
+			err.URL = resp.Request.URL.Redacted()
+		}
+	}
+
+	for _, key := range statusErrorHeaders {
+		key = textproto.CanonicalMIMEHeaderKey(key)
+		if values := resp.Header[key]; len(values) > 0 {
+			err.Header[key] = append([]string(nil), values...)
+		}
+	}
+
+	if resp.Body != nil {
+		err.Body, _ = io.ReadAll(io.LimitReader(resp.Body, MaxErrorBody))
+	}
+
+	err.Problem = parseProblem(resp.Header, err.Body)
+
+	return err
+}
+
+func (err *StatusError) Error() string {
+	status := err.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", err.StatusCode, http.StatusText(err.StatusCode))
+	}
+
+	msg := "unexpected status " + status
+	if err.Method != "" || err.URL != "" {
+		msg = fmt.Sprintf("%s %s: %s", err.Method, err.URL, msg)
+	}
+
+	if err.Problem != nil {
+		msg += ": " + err.Problem.Error()
+	}
+
+	return msg
+}
+
+// Unwrap returns the decoded problem details, if any.
+func (err *StatusError) Unwrap() error {
+	if err.Problem == nil {
+		return nil
+	}
+	return err.Problem
+}
+
+func isSuccess(code int) bool {
+	return Status2xx.Contains(code)
+}
+
+// maxDrain limits the amount of data read from the response body before closing it.
+// Draining allows the underlying connection to be reused.
+const maxDrain = 64 << 10
+
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, maxDrain))
+	_ = body.Close()
+}
@@ -0,0 +1,122 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ninedraft/httpclient"
+)
+
+func TestClient_AcceptStatus(t *testing.T) {
+	t.Parallel()
+
+	calls := map[string]callFn{
+		"Get": (*httpclient.Client).Get,
+		"PostJSON": func(cl *httpclient.Client, ctx context.Context, addr string, _ ...httpclient.RequestOption) (*http.Response, error) {
+			return cl.PostJSON(ctx, addr, map[string]string{"foo": "bar"})
+		},
+		"PostForm": func(cl *httpclient.Client, ctx context.Context, addr string, _ ...httpclient.RequestOption) (*http.Response, error) {
+			return cl.PostForm(ctx, addr, url.Values{"foo": {"bar"}})
+		},
+		"GetForm": func(cl *httpclient.Client, ctx context.Context, addr string, _ ...httpclient.RequestOption) (*http.Response, error) {
+			return cl.GetForm(ctx, addr, url.Values{"foo": {"bar"}})
+		},
+		"PostMultipart": func(cl *httpclient.Client, ctx context.Context, addr string, _ ...httpclient.RequestOption) (*http.Response, error) {
+			return cl.PostMultipart(ctx, addr, httpclient.MultipartFields(url.Values{"foo": {"bar"}}))
+		},
+	}
+
+	for name, call := range calls {
+		name, call := name, call
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.Copy(io.Discard, r.Body)
+
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("Retry-After", "10")
+				w.Header().Set("Set-Cookie", "secret=value")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = io.WriteString(w, "try again later")
+			})
+			defer server.Assert(t)
+
+			client := httpclient.NewFrom(server.Client())
+			client.AcceptStatus = []httpclient.StatusRange{httpclient.Status2xx}
+
+			resp, errCall := call(client, context.Background(), server.URL)
+
+			assertEqual(t, nil, resp, "response")
+
+			var statusErr *httpclient.StatusError
+			requireEqual(t, true, errors.As(errCall, &statusErr), "expected status error, got %v", errCall)
+			assertEqual(t, http.StatusServiceUnavailable, statusErr.StatusCode, "status code")
+			assertEqual(t, "10", statusErr.Header.Get("Retry-After"), "retry-after header")
+			assertEqual(t, "", statusErr.Header.Get("Set-Cookie"), "set-cookie header")
+			assertEqual(t, "try again later", string(statusErr.Body), "body snapshot")
+		})
+	}
+}
+
+func TestClient_AcceptStatusRanges(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.AcceptStatus = []httpclient.StatusRange{
+		httpclient.Status2xx,
+		httpclient.StatusCode(http.StatusNotFound),
+	}
+
+	resp, errCall := client.Get(context.Background(), server.URL)
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+	assertEqual(t, http.StatusNotFound, resp.StatusCode, "status code")
+}
+
+func TestClient_NoAcceptStatus(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+
+	resp, errCall := client.Get(context.Background(), server.URL)
+
+	requireEqual(t, nil, errCall, "call error")
+	defer resp.Body.Close()
+	assertEqual(t, http.StatusInternalServerError, resp.StatusCode, "status code")
+}
+
+func TestStatusError_BodyLimit(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = io.WriteString(w, strings.Repeat("x", 2*httpclient.MaxErrorBody))
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.AcceptStatus = []httpclient.StatusRange{httpclient.Status2xx}
+
+	_, errCall := client.Get(context.Background(), server.URL)
+
+	var statusErr *httpclient.StatusError
+	requireEqual(t, true, errors.As(errCall, &statusErr), "expected status error, got %v", errCall)
+	assertEqual(t, httpclient.MaxErrorBody, len(statusErr.Body), "body snapshot size")
+}
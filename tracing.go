@@ -0,0 +1,334 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerTraceparent = "Traceparent"
+	headerTracestate  = "Tracestate"
+	headerBaggage     = "Baggage"
+)
+
+var (
+	errInvalidTraceparent = errors.New("invalid traceparent")
+	errInvalidBaggage     = errors.New("invalid baggage")
+)
+
+// SpanContext identifies a span in a distributed trace, as defined by W3C Trace Context.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	// Flags are the trace flags, see SpanContext.Sampled.
+	Flags byte
+	// TraceState is the vendor-specific tracestate header value.
+	TraceState string
+}
+
+// IsValid reports whether the trace and span IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Sampled reports whether the sampled trace flag is set.
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&0x01 != 0
+}
+
+// Traceparent returns the traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// ParseTraceparent parses the traceparent header value.
+// Values of future versions are accepted, their extra fields are ignored.
+func ParseTraceparent(value string) (SpanContext, error) {
+	value = strings.TrimSpace(value)
+	// "00-" + 32 hex + "-" + 16 hex + "-" + 2 hex
+	const size = 55
+	if len(value) < size || (len(value) > size && value[size] != '-') {
+		return SpanContext{}, errInvalidTraceparent
+	}
+
+	fields := strings.Split(value[:size], "-")
+	if len(fields) != 4 || fields[0] == "ff" || (fields[0] == "00" && len(value) != size) {
+		return SpanContext{}, errInvalidTraceparent
+	}
+
+	sc := SpanContext{}
+	var version, flags [1]byte
+	for _, field := range []struct {
+		dst []byte
+		src string
+	}{
+		{version[:], fields[0]},
+		{sc.TraceID[:], fields[1]},
+		{sc.SpanID[:], fields[2]},
+		{flags[:], fields[3]},
+	} {
+		if len(field.src) != 2*len(field.dst) || strings.ToLower(field.src) != field.src {
+			return SpanContext{}, errInvalidTraceparent
+		}
+		if _, err := hex.Decode(field.dst, []byte(field.src)); err != nil {
+			return SpanContext{}, errInvalidTraceparent
+		}
+	}
+	sc.Flags = flags[0]
+
+	if !sc.IsValid() {
+		return SpanContext{}, errInvalidTraceparent
+	}
+	return sc, nil
+}
+
+type spanContextKey struct{}
+
+// WithSpanContext sets the span context which is propagated by the Tracing wrapper
+// and used as the parent of the client spans.
+func WithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFrom returns the span context set by WithSpanContext.
+func SpanContextFrom(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+// Baggage is a set of W3C Baggage name-value pairs propagated with requests.
+type Baggage map[string]string
+
+// ParseBaggage parses the baggage header value. Member properties are ignored.
+func ParseBaggage(value string) (Baggage, error) {
+	baggage := Baggage{}
+	for _, member := range strings.Split(value, ",") {
+		member, _, _ = strings.Cut(member, ";")
+		if strings.TrimSpace(member) == "" {
+			continue
+		}
+
+		key, val, ok := strings.Cut(member, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !isToken(key) {
+			return nil, errInvalidBaggage
+		}
+
+		decoded, errDecode := url.PathUnescape(strings.TrimSpace(val))
+		if errDecode != nil {
+			return nil, errInvalidBaggage
+		}
+		baggage[key] = decoded
+	}
+	return baggage, nil
+}
+
+// String returns the baggage header value. Members with invalid names are skipped.
+func (baggage Baggage) String() string {
+	keys := make([]string, 0, len(baggage))
+	for key := range baggage {
+		if isToken(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	members := make([]string, 0, len(keys))
+	for _, key := range keys {
+		members = append(members, key+"="+escapeBaggageValue(baggage[key]))
+	}
+	return strings.Join(members, ",")
+}
+
+func escapeBaggageValue(value string) string {
+	const hexDigits = "0123456789ABCDEF"
+
+	escaped := strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		// baggage-octet from the W3C Baggage specification, without the percent sign
+		if c > 0x20 && c < 0x7f && c != '"' && c != ',' && c != ';' && c != '\\' && c != '%' {
+			escaped.WriteByte(c)
+			continue
+		}
+		escaped.WriteByte('%')
+		escaped.WriteByte(hexDigits[c>>4])
+		escaped.WriteByte(hexDigits[c&0x0f])
+	}
+	return escaped.String()
+}
+
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= 0x20 || c >= 0x7f || strings.IndexByte(`()<>@,;:\"/[]?={}`, c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+type baggageKey struct{}
+
+// WithBaggage sets the baggage which is propagated by the Tracing wrapper.
+func WithBaggage(ctx context.Context, baggage Baggage) context.Context {
+	return context.WithValue(ctx, baggageKey{}, baggage)
+}
+
+// BaggageFrom returns the baggage set by WithBaggage.
+func BaggageFrom(ctx context.Context) Baggage {
+	baggage, _ := ctx.Value(baggageKey{}).(Baggage)
+	return baggage
+}
+
+// ExtractTraceContext returns a context with the span context and baggage of the incoming request headers.
+// It is used by servers to continue the trace in outbound requests.
+// Invalid headers are ignored.
+func ExtractTraceContext(ctx context.Context, header http.Header) context.Context {
+	sc, errParse := ParseTraceparent(header.Get(headerTraceparent))
+	if errParse == nil {
+		sc.TraceState = strings.Join(header.Values(headerTracestate), ",")
+		ctx = WithSpanContext(ctx, sc)
+	}
+
+	if values := header.Values(headerBaggage); len(values) > 0 {
+		if baggage, errBaggage := ParseBaggage(strings.Join(values, ",")); errBaggage == nil {
+			ctx = WithBaggage(ctx, baggage)
+		}
+	}
+
+	return ctx
+}
+
+// Tracer starts client spans. See the oteltrace module for an OpenTelemetry adapter.
+type Tracer interface {
+	// Start starts a client span as a child of the span in the context.
+	// The returned context contains the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a client span started by a Tracer.
+type Span interface {
+	// SpanContext returns the span context propagated to the server.
+	SpanContext() SpanContext
+	SetAttribute(key string, value any)
+	// SetError marks the span as failed.
+	SetError(err error)
+	End()
+}
+
+// TracingOptions configures the Tracing wrapper.
+type TracingOptions struct {
+	// Tracer starts a client span for each request.
+	// If nil, the span context from the request context is propagated as is.
+	Tracer Tracer
+
+	// Baggage enables propagation of the baggage set by WithBaggage.
+	Baggage bool
+}
+
+// Tracing creates a wrapper which propagates the trace context
+// using the traceparent, tracestate and baggage headers.
+//
+// Spans are named after the method and the route, see WithRoute,
+// and have OpenTelemetry semantic convention attributes:
+// http.request.method, url.full without the query, url.template, server.address,
+// http.request.resend_count, http.response.status_code and error.type.
+// Transport errors and 5xx responses mark the span as failed.
+// The span ends when the response headers are received.
+//
+// Add the wrapper after Retry to create a span for every attempt.
+func Tracing(opts TracingOptions) Wrapper {
+	return func(next Doer) Doer {
+		return DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return opts.do(next, req)
+		})
+	}
+}
+
+func (opts TracingOptions) do(next Doer, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	route := Route(ctx)
+
+	var span Span
+	sc := SpanContextFrom(ctx)
+	if opts.Tracer != nil {
+		name := req.Method
+		if route != "" {
+			name += " " + route
+		}
+		ctx, span = opts.Tracer.Start(ctx, name)
+		sc = span.SpanContext()
+		ctx = WithSpanContext(ctx, sc)
+		setRequestAttributes(span, req, route)
+	}
+
+	req = req.Clone(ctx)
+	if sc.IsValid() {
+		req.Header.Set(headerTraceparent, sc.Traceparent())
+		req.Header.Del(headerTracestate)
+		if sc.TraceState != "" {
+			req.Header.Set(headerTracestate, sc.TraceState)
+		}
+	}
+	if baggage := BaggageFrom(ctx); opts.Baggage && len(baggage) > 0 {
+		req.Header.Set(headerBaggage, baggage.String())
+	}
+
+	resp, err := next.Do(req)
+
+	if span != nil {
+		switch {
+		case err != nil:
+			span.SetAttribute("error.type", errorType(err))
+			span.SetError(err)
+		case resp.StatusCode >= 500:
+			span.SetAttribute("http.response.status_code", resp.StatusCode)
+			span.SetAttribute("error.type", strconv.Itoa(resp.StatusCode))
+			span.SetError(errors.New(resp.Status))
+		default:
+			span.SetAttribute("http.response.status_code", resp.StatusCode)
+		}
+		span.End()
+	}
+
+	return resp, err
+}
+
+func setRequestAttributes(span Span, req *http.Request, route string) {
+	u := *req.URL
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+
+	span.SetAttribute("http.request.method", req.Method)
+	span.SetAttribute("url.full", u.String())
+	span.SetAttribute("server.address", req.URL.Hostname())
+	if route != "" {
+		span.SetAttribute("url.template", route)
+	}
+	if attempt := RetryAttempt(req.Context()); attempt > 1 {
+		span.SetAttribute("http.request.resend_count", attempt-1)
+	}
+}
+
+// errorType returns a low-cardinality description of the transport error.
+func errorType(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "_OTHER"
+	}
+}
@@ -0,0 +1,250 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ninedraft/httpclient"
+)
+
+// fakeTracer creates spans with sequential span IDs.
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*fakeSpan
+}
+
+type fakeSpan struct {
+	name   string
+	sc     httpclient.SpanContext
+	parent httpclient.SpanContext
+	attrs  map[string]any
+	err    error
+	ended  bool
+}
+
+func (tracer *fakeTracer) Start(ctx context.Context, name string) (context.Context, httpclient.Span) {
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+
+	parent := httpclient.SpanContextFrom(ctx)
+	span := &fakeSpan{name: name, parent: parent, attrs: map[string]any{}}
+	span.sc = httpclient.SpanContext{TraceID: parent.TraceID, Flags: 0x01, TraceState: parent.TraceState}
+	if span.sc.TraceID == [16]byte{} {
+		span.sc.TraceID = [16]byte{0xaa}
+	}
+	span.sc.SpanID[7] = byte(len(tracer.spans) + 1)
+	tracer.spans = append(tracer.spans, span)
+
+	return ctx, span
+}
+
+func (span *fakeSpan) SpanContext() httpclient.SpanContext { return span.sc }
+func (span *fakeSpan) SetAttribute(key string, value any)  { span.attrs[key] = value }
+func (span *fakeSpan) SetError(err error)                  { span.err = err }
+func (span *fakeSpan) End()                                { span.ended = true }
+
+func TestParseTraceparent(t *testing.T) {
+	t.Parallel()
+
+	const valid = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	sc, err := httpclient.ParseTraceparent(valid)
+	requireEqual(t, nil, err, "parse error")
+	assertEqual(t, valid, sc.Traceparent(), "round trip")
+	assertEqual(t, true, sc.Sampled(), "sampled")
+	assertEqual(t, 0x4b, sc.TraceID[0], "trace id")
+	assertEqual(t, 0xb7, sc.SpanID[7], "span id")
+
+	sc, err = httpclient.ParseTraceparent("cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future")
+	requireEqual(t, nil, err, "future version parse error")
+	assertEqual(t, false, sc.Sampled(), "future version sampled")
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-600f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bz-01",
+		"cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01x",
+	} {
+		_, err := httpclient.ParseTraceparent(invalid)
+		assertNotEqual(t, nil, err, "invalid traceparent %q", invalid)
+	}
+}
+
+func TestBaggage(t *testing.T) {
+	t.Parallel()
+
+	baggage, err := httpclient.ParseBaggage("user=alice, region = eu-west%201;prop=1,,empty=")
+	requireEqual(t, nil, err, "parse error")
+	assertEqual(t, 3, len(baggage), "members")
+	assertEqual(t, "alice", baggage["user"], "user")
+	assertEqual(t, "eu-west 1", baggage["region"], "region")
+	assertEqual(t, "", baggage["empty"], "empty")
+
+	encoded := httpclient.Baggage{"b": "x,y;z%", "a": "1", "bad key": "skipped"}.String()
+	assertEqual(t, "a=1,b=x%2Cy%3Bz%25", encoded, "encoded baggage")
+
+	_, err = httpclient.ParseBaggage("no-value")
+	assertNotEqual(t, nil, err, "member without value")
+}
+
+func TestExtractTraceContext(t *testing.T) {
+	t.Parallel()
+
+	header := http.Header{}
+	header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Add("Tracestate", "vendor=a")
+	header.Add("Tracestate", "other=b")
+	header.Set("Baggage", "user=alice")
+
+	ctx := httpclient.ExtractTraceContext(context.Background(), header)
+	sc := httpclient.SpanContextFrom(ctx)
+	assertEqual(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.Traceparent(), "traceparent")
+	assertEqual(t, "vendor=a,other=b", sc.TraceState, "tracestate")
+	assertEqual(t, "alice", httpclient.BaggageFrom(ctx)["user"], "baggage")
+
+	header.Set("Traceparent", "garbage")
+	ctx = httpclient.ExtractTraceContext(context.Background(), header)
+	assertEqual(t, false, httpclient.SpanContextFrom(ctx).IsValid(), "invalid traceparent is ignored")
+}
+
+func TestTracing_Propagation(t *testing.T) {
+	t.Parallel()
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, traceparent, r.Header.Get("Traceparent"), "traceparent")
+		assertEqual(t, "vendor=a", r.Header.Get("Tracestate"), "tracestate")
+		assertEqual(t, "user=alice", r.Header.Get("Baggage"), "baggage")
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Tracing(httpclient.TracingOptions{Baggage: true}))
+
+	sc, err := httpclient.ParseTraceparent(traceparent)
+	requireEqual(t, nil, err, "parse error")
+	sc.TraceState = "vendor=a"
+
+	ctx := httpclient.WithSpanContext(context.Background(), sc)
+	ctx = httpclient.WithBaggage(ctx, httpclient.Baggage{"user": "alice"})
+
+	resp, err := client.Get(ctx, server.URL)
+	requireEqual(t, nil, err, "call error")
+	resp.Body.Close()
+}
+
+func TestTracing_NoBaggage(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assertEqual(t, "", r.Header.Get("Traceparent"), "traceparent")
+		assertEqual(t, "", r.Header.Get("Baggage"), "baggage")
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Tracing(httpclient.TracingOptions{}))
+
+	ctx := httpclient.WithBaggage(context.Background(), httpclient.Baggage{"user": "alice"})
+	resp, err := client.Get(ctx, server.URL)
+	requireEqual(t, nil, err, "call error")
+	resp.Body.Close()
+}
+
+func TestTracing_Spans(t *testing.T) {
+	t.Parallel()
+
+	server, _ := flakyServer(t, 1, http.StatusServiceUnavailable, nil)
+	defer server.Assert(t)
+
+	tracer := &fakeTracer{}
+	client := httpclient.NewFrom(server.Client())
+	client.Use(
+		httpclient.Retry(httpclient.RetryOptions{BaseDelay: time.Millisecond}),
+		httpclient.Tracing(httpclient.TracingOptions{Tracer: tracer}),
+	)
+
+	parent, err := httpclient.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	requireEqual(t, nil, err, "parse error")
+	ctx := httpclient.WithSpanContext(context.Background(), parent)
+	ctx = httpclient.WithRoute(ctx, "/items/{id}")
+
+	resp, err := client.Get(ctx, server.URL+"/items/42?token=secret")
+	requireEqual(t, nil, err, "call error")
+	resp.Body.Close()
+
+	requireEqual(t, 2, len(tracer.spans), "spans")
+	for i, span := range tracer.spans {
+		assertEqual(t, "GET /items/{id}", span.name, "span %d name", i)
+		assertEqual(t, parent, span.parent, "span %d parent", i)
+		assertEqual(t, true, span.ended, "span %d ended", i)
+		assertEqual[any](t, "GET", span.attrs["http.request.method"], "span %d method", i)
+		assertEqual[any](t, server.URL+"/items/42", span.attrs["url.full"], "span %d url", i)
+		assertEqual[any](t, "/items/{id}", span.attrs["url.template"], "span %d url template", i)
+		assertEqual[any](t, "127.0.0.1", span.attrs["server.address"], "span %d server address", i)
+	}
+
+	first, second := tracer.spans[0], tracer.spans[1]
+	assertEqual[any](t, http.StatusServiceUnavailable, first.attrs["http.response.status_code"], "failed status")
+	assertEqual[any](t, "503", first.attrs["error.type"], "failed error type")
+	assertNotEqual(t, nil, first.err, "failed span error")
+	assertEqual[any](t, nil, first.attrs["http.request.resend_count"], "first attempt resend count")
+
+	assertEqual[any](t, http.StatusOK, second.attrs["http.response.status_code"], "status")
+	assertEqual(t, nil, second.err, "span error")
+	assertEqual[any](t, 1, second.attrs["http.request.resend_count"], "resend count")
+}
+
+func TestTracing_SpanHeaders(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		sc, err := httpclient.ParseTraceparent(r.Header.Get("Traceparent"))
+		requireEqual(t, nil, err, "parse error")
+		assertEqual(t, 1, sc.SpanID[7], "span id of the client span")
+		assertEqual(t, 0xaa, sc.TraceID[0], "new trace id")
+	})
+	defer server.Assert(t)
+
+	tracer := &fakeTracer{}
+	client := httpclient.NewFrom(server.Client())
+	client.Use(httpclient.Tracing(httpclient.TracingOptions{Tracer: tracer}))
+
+	resp, err := client.Get(context.Background(), server.URL)
+	requireEqual(t, nil, err, "call error")
+	resp.Body.Close()
+
+	requireEqual(t, 1, len(tracer.spans), "spans")
+	assertEqual(t, "GET", tracer.spans[0].name, "span name without route")
+}
+
+func TestTracing_Error(t *testing.T) {
+	t.Parallel()
+
+	tracer := &fakeTracer{}
+	client := httpclient.NewFrom(httpclient.DoerFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, req.Context().Err()
+	}))
+	client.Use(httpclient.Tracing(httpclient.TracingOptions{Tracer: tracer}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := client.Get(ctx, "http://example.com/")
+	assertEqual(t, true, errors.Is(err, context.Canceled), "unexpected error: %v", err)
+
+	requireEqual(t, 1, len(tracer.spans), "spans")
+	span := tracer.spans[0]
+	assertEqual(t, true, errors.Is(span.err, context.Canceled), "span error: %v", span.err)
+	assertEqual[any](t, "canceled", span.attrs["error.type"], "error type")
+	assertEqual(t, true, span.ended, "span ended")
+}
@@ -1,14 +1,67 @@
 package httpclient_test
 
 import (
+	"fmt"
 	"io"
+	"os"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"golang.org/x/exp/slices"
 )
 
 const MethodQuery = "QUERY"
 
+// leakMarkers are functions which must not have running goroutines after the tests.
+var leakMarkers = []string{
+	"httpclient.(*Client).doMultipartStream",
+	"httpclient.(*encodedBody).encode",
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+
+	if leaked := leakedGoroutines(time.Second); code == 0 && leaked != "" {
+		fmt.Fprintf(os.Stderr, "leaked goroutines:\n%s\n", leaked)
+		code = 1
+	}
+
+	os.Exit(code)
+}
+
+// leakedGoroutines waits for the goroutines matching leakMarkers to finish
+// and returns their stacks if they are still running after the timeout.
+func leakedGoroutines(timeout time.Duration) string {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		leaked := findGoroutines(leakMarkers...)
+		if leaked == "" || time.Now().After(deadline) {
+			return leaked
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func findGoroutines(markers ...string) string {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+
+	var found []string
+	for _, stack := range strings.Split(string(buf), "\n\n") {
+		for _, marker := range markers {
+			if strings.Contains(stack, marker) {
+				found = append(found, stack)
+				break
+			}
+		}
+	}
+
+	return strings.Join(found, "\n\n")
+}
+
 func assertEqual[E comparable](t *testing.T, expected, actual E, msg string, args ...any) {
 	t.Helper()
 
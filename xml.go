@@ -0,0 +1,109 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+var errUnsupportedCharset = errors.New("unsupported charset")
+
+// PostXML makes a POST request to the given address with XML-encoded body.
+func (client *Client) PostXML(ctx context.Context, addr string, obj any, opts ...RequestOption) (*http.Response, error) {
+	return client.PostAs(ctx, addr, XMLCodec{}, obj, opts...)
+}
+
+// PutXML makes a PUT request to the given address with XML-encoded body.
+func (client *Client) PutXML(ctx context.Context, addr string, obj any, opts ...RequestOption) (*http.Response, error) {
+	return client.PutAs(ctx, addr, XMLCodec{}, obj, opts...)
+}
+
+// PatchXML makes a PATCH request to the given address with XML-encoded body.
+func (client *Client) PatchXML(ctx context.Context, addr string, obj any, opts ...RequestOption) (*http.Response, error) {
+	return client.PatchAs(ctx, addr, XMLCodec{}, obj, opts...)
+}
+
+// QueryXML makes a QUERY request to the given address with XML-encoded body.
+func (client *Client) QueryXML(ctx context.Context, addr string, obj any, opts ...RequestOption) (*http.Response, error) {
+	return client.QueryAs(ctx, addr, XMLCodec{}, obj, opts...)
+}
+
+// GetXML makes a GET request to the given address and decodes the XML response into a value of type T.
+// Responses with non-2xx status codes are reported as *StatusError.
+func GetXML[T any](ctx context.Context, client *Client, addr string, opts ...RequestOption) (T, error) {
+	return DecodeXML[T](client.Get(ctx, addr, opts...))
+}
+
+// DeleteXML makes a DELETE request to the given address and decodes the XML response into a value of type T.
+// Responses with non-2xx status codes are reported as *StatusError.
+func DeleteXML[T any](ctx context.Context, client *Client, addr string, opts ...RequestOption) (T, error) {
+	return DecodeXML[T](client.Delete(ctx, addr, opts...))
+}
+
+// DoXML makes a request with the given method and XML-encoded body
+// and decodes the XML response into a value of type Resp.
+// Responses with non-2xx status codes are reported as *StatusError.
+func DoXML[Req, Resp any](ctx context.Context, client *Client, method, addr string, obj Req, opts ...RequestOption) (Resp, error) {
+	return DecodeXML[Resp](client.NewRequest(method, addr).XML(obj).Apply(opts...).Do(ctx))
+}
+
+// DecodeXML decodes the XML-encoded response body into a value of type T.
+// It accepts the results of a request call as is, so it can be chained with any of the Client methods.
+// The response body is always drained and closed.
+// Responses with non-2xx status codes are reported as *StatusError.
+// An empty response body results in a zero value of T.
+//
+// The charset parameter of the Content-Type header takes precedence over the encoding of the XML declaration.
+// Supported charsets are UTF-8, US-ASCII and ISO-8859-1.
+func DecodeXML[T any](resp *http.Response, errDo error) (T, error) {
+	var value T
+	err := decodeXML(resp, errDo, &value)
+	return value, err
+}
+
+func decodeXML(resp *http.Response, errDo error, dst any) error {
+	return decodeBody(resp, errDo, dst, withCodec(XMLCodec{}), isSuccess)
+}
+
+// charsetReader converts the input in the given charset to UTF-8.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "utf-8", "utf8":
+		return input, nil
+	// US-ASCII is a subset of ISO-8859-1
+	case "us-ascii", "ascii", "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "l1":
+		return &latin1Reader{src: input}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnsupportedCharset, charset)
+	}
+}
+
+// latin1Reader converts ISO-8859-1 to UTF-8.
+type latin1Reader struct {
+	src io.Reader
+	buf []byte
+	err error
+}
+
+func (r *latin1Reader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		var raw [512]byte
+		n, err := r.src.Read(raw[:])
+		for _, b := range raw[:n] {
+			r.buf = utf8.AppendRune(r.buf, rune(b))
+		}
+		r.err = err
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
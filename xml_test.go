@@ -0,0 +1,254 @@
+package httpclient_test
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/ninedraft/httpclient"
+)
+
+var methodsXML = map[string]methodJSON{
+	http.MethodPost:  (*httpclient.Client).PostXML,
+	http.MethodPut:   (*httpclient.Client).PutXML,
+	http.MethodPatch: (*httpclient.Client).PatchXML,
+	MethodQuery:      (*httpclient.Client).QueryXML,
+}
+
+func TestClient_XML(t *testing.T) {
+	type request struct {
+		XMLName xml.Name `xml:"request"`
+		Foo     string   `xml:"foo"`
+	}
+
+	var requestBody = request{
+		XMLName: xml.Name{Local: "request"},
+		Foo:     "bar",
+	}
+
+	tc := func(method string, call methodJSON) {
+		t.Run(method, func(t *testing.T) {
+			t.Parallel()
+
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				assertEqual(t, method, r.Method, "http method")
+				assertEqual(t, "application/xml", r.Header.Get("Content-Type"), "content-type")
+				assertEqual(t, "<request><foo>bar</foo></request>", readString(t, r.Body), "request body")
+
+				w.WriteHeader(http.StatusOK)
+			})
+			defer server.Assert(t)
+
+			client := httpclient.NewFrom(server.Client())
+			ctx := context.Background()
+
+			resp, errCall := call(client, ctx, server.URL, requestBody)
+
+			if resp != nil {
+				defer resp.Body.Close()
+			}
+
+			requireEqual(t, nil, errCall, "call error")
+			assertEqual(t, http.StatusOK, resp.StatusCode, "status code")
+		})
+	}
+
+	for method, call := range methodsXML {
+		tc(method, call)
+	}
+}
+
+func TestDoXML(t *testing.T) {
+	type request struct {
+		Foo string `xml:"foo"`
+	}
+
+	type response struct {
+		Method string `xml:"method"`
+		Foo    string `xml:"foo"`
+	}
+
+	tc := func(method string) {
+		t.Run(method, func(t *testing.T) {
+			t.Parallel()
+
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				assertEqual(t, method, r.Method, "http method")
+
+				got := request{}
+				assertEqual(t, nil, xml.NewDecoder(r.Body).Decode(&got), "request body")
+
+				w.Header().Set("Content-Type", "application/xml")
+				_ = xml.NewEncoder(w).Encode(response{Method: r.Method, Foo: got.Foo})
+			})
+			defer server.Assert(t)
+
+			client := httpclient.NewFrom(server.Client())
+			ctx := context.Background()
+
+			got, errCall := httpclient.DoXML[request, response](ctx, client, method, server.URL, request{Foo: "bar"})
+
+			requireEqual(t, nil, errCall, "call error")
+			assertEqual(t, response{Method: method, Foo: "bar"}, got, "response")
+		})
+	}
+
+	for method := range methodsXML {
+		tc(method)
+	}
+}
+
+func TestGetXML(t *testing.T) {
+	t.Parallel()
+
+	type response struct {
+		Foo string `xml:"foo"`
+	}
+
+	calls := map[string]func(ctx context.Context, client *httpclient.Client, addr string, opts ...httpclient.RequestOption) (response, error){
+		http.MethodGet:    httpclient.GetXML[response],
+		http.MethodDelete: httpclient.DeleteXML[response],
+	}
+
+	for method, call := range calls {
+		method, call := method, call
+		t.Run(method, func(t *testing.T) {
+			t.Parallel()
+
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				assertEqual(t, method, r.Method, "http method")
+				_, _ = io.WriteString(w, `<response><foo>bar</foo></response>`)
+			})
+			defer server.Assert(t)
+
+			client := httpclient.NewFrom(server.Client())
+
+			got, errCall := call(context.Background(), client, server.URL)
+
+			requireEqual(t, nil, errCall, "call error")
+			assertEqual(t, response{Foo: "bar"}, got, "response")
+		})
+	}
+}
+
+func TestGetXML_StatusError(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `<error>not found</error>`)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+
+	_, errCall := httpclient.GetXML[string](context.Background(), client, server.URL)
+
+	var statusErr *httpclient.StatusError
+	requireEqual(t, true, errors.As(errCall, &statusErr), "expected status error, got %v", errCall)
+	assertEqual(t, http.StatusNotFound, statusErr.StatusCode, "status code")
+	assertEqual(t, http.MethodGet, statusErr.Method, "method")
+	assertEqual(t, server.URL, statusErr.URL, "url")
+}
+
+func TestGetXML_EmptyBody(t *testing.T) {
+	t.Parallel()
+
+	server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer server.Assert(t)
+
+	client := httpclient.NewFrom(server.Client())
+
+	got, errCall := httpclient.GetXML[string](context.Background(), client, server.URL)
+
+	requireEqual(t, nil, errCall, "call error")
+	assertEqual(t, "", got, "response")
+}
+
+func TestGetXML_Charset(t *testing.T) {
+	t.Parallel()
+
+	type response struct {
+		Name string `xml:"name"`
+	}
+
+	tcs := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:        "utf-8",
+			contentType: "application/xml; charset=utf-8",
+			body:        "<response><name>café</name></response>",
+			want:        "café",
+		},
+		{
+			name:        "header charset",
+			contentType: "text/xml; charset=ISO-8859-1",
+			body:        "<response><name>caf\xe9</name></response>",
+			want:        "café",
+		},
+		{
+			name:        "header charset overrides declaration",
+			contentType: "application/xml; charset=latin1",
+			body:        `<?xml version="1.0" encoding="windows-1251"?><response><name>caf` + "\xe9" + `</name></response>`,
+			want:        "café",
+		},
+		{
+			name:        "declaration",
+			contentType: "application/xml",
+			body:        `<?xml version="1.0" encoding="ISO-8859-1"?><response><name>caf` + "\xe9" + `</name></response>`,
+			want:        "café",
+		},
+		{
+			name:        "us-ascii",
+			contentType: "application/xml; charset=us-ascii",
+			body:        "<response><name>cafe</name></response>",
+			want:        "cafe",
+		},
+		{
+			name:        "unsupported header charset",
+			contentType: "application/xml; charset=koi8-r",
+			body:        "<response><name>cafe</name></response>",
+			wantErr:     true,
+		},
+		{
+			name:        "unsupported declaration",
+			contentType: "application/xml",
+			body:        `<?xml version="1.0" encoding="koi8-r"?><response><name>cafe</name></response>`,
+			wantErr:     true,
+		},
+	}
+
+	for _, tc := range tcs {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := testServer(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				_, _ = io.WriteString(w, tc.body)
+			})
+			defer server.Assert(t)
+
+			client := httpclient.NewFrom(server.Client())
+
+			got, errCall := httpclient.GetXML[response](context.Background(), client, server.URL)
+
+			if tc.wantErr {
+				assertNotEqual(t, nil, errCall, "call error")
+				return
+			}
+			requireEqual(t, nil, errCall, "call error")
+			assertEqual(t, tc.want, got.Name, "response")
+		})
+	}
+}